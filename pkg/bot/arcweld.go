@@ -1,13 +1,14 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
 
 type ArcweldArgs struct {
-	ImageURL string `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL string `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 }
 
 func (args ArcweldArgs) GetImageURL() string {
@@ -15,7 +16,7 @@ func (args ArcweldArgs) GetImageURL() string {
 }
 
 // Arcweld destroys an image via a combination of operations.
-func Arcweld(wand *imagick.MagickWand, args ArcweldArgs) ([]*imagick.MagickWand, error) {
+func Arcweld(_ context.Context, wand *imagick.MagickWand, args ArcweldArgs) ([]*imagick.MagickWand, error) {
 	origMask := wand.SetImageChannelMask(imagick.CHANNEL_RED)
 	err := wand.EvaluateImage(imagick.EVAL_OP_LEFT_SHIFT, 1)
 	if err != nil {
@@ -0,0 +1,159 @@
+package bot
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// maxOptionChoices is the most choices Discord allows an option, or an autocomplete response, to have.
+const maxOptionChoices = 25
+
+// autocompleteProviders supply the values of arguments tagged with autocomplete, keyed by the tag's value.
+// Unlike choices, the values may change at runtime or be too many to list in full.
+var autocompleteProviders = map[string]func() []string{
+	"blend_modes": func() []string {
+		return slices.Sorted(maps.Keys(blendModes))
+	},
+	"commands": func() []string {
+		return slices.Compact(slices.Sorted(maps.Values(commandNames)))
+	},
+}
+
+// commandArgsTypes maps the name and every alias of each enabled command to the type of its text arguments.
+var commandArgsTypes = map[string]reflect.Type{}
+
+// argChoices returns the values an argument is limited to, as declared by its choices or autocomplete tag.
+func argChoices(field reflect.StructField) []string {
+	if choices, ok := field.Tag.Lookup("choices"); ok {
+		return strings.Split(choices, ",")
+	}
+	if name, ok := field.Tag.Lookup("autocomplete"); ok {
+		if provider, ok := autocompleteProviders[name]; ok {
+			return provider()
+		}
+		log.Warn().Str("provider", name).Msg("Unknown autocomplete provider")
+	}
+	return nil
+}
+
+// validateArgs checks that each argument limited to a set of values has one of them.
+func validateArgs(args any) error {
+	value := reflect.ValueOf(args)
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for index := 0; index < value.NumField(); index++ {
+		field := value.Type().Field(index)
+		if field.Type.Kind() != reflect.String {
+			continue
+		}
+		choices := argChoices(field)
+		if choices == nil {
+			continue
+		}
+
+		current := value.Field(index).String()
+		if defaultValue, ok := field.Tag.Lookup("default"); ok && current == defaultValue {
+			continue
+		}
+		if !slices.ContainsFunc(choices, func(choice string) bool { return strings.EqualFold(choice, current) }) {
+			return newUserError("%q isn't a valid %s. Try one of: %s.", current, field.Name, strings.Join(choices, ", "))
+		}
+	}
+
+	return nil
+}
+
+// optionMatchesField reports whether a slash command option was generated from an argument struct field.
+func optionMatchesField(option string, field string) bool {
+	return strings.EqualFold(strings.ReplaceAll(option, "_", ""), field)
+}
+
+// findArgsFieldForOption returns the argument struct field a slash command option was generated from.
+func findArgsFieldForOption(argsType reflect.Type, option string) (reflect.StructField, bool) {
+	for index := 0; index < argsType.NumField(); index++ {
+		if field := argsType.Field(index); optionMatchesField(option, field.Name) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// applyOptionChoices adds the choices and autocomplete declared on argument fields to the options of a slash command.
+func applyOptionChoices(options []*discordgo.ApplicationCommandOption, argsType reflect.Type) {
+	for _, option := range options {
+		field, ok := findArgsFieldForOption(argsType, option.Name)
+		if !ok || option.Type != discordgo.ApplicationCommandOptionString {
+			continue
+		}
+
+		if choices, ok := field.Tag.Lookup("choices"); ok {
+			for _, choice := range strings.Split(choices, ",") {
+				option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{
+					Name:  choice,
+					Value: choice,
+				})
+			}
+		} else if _, ok := field.Tag.Lookup("autocomplete"); ok {
+			option.Autocomplete = true
+		}
+	}
+}
+
+// focusedOption returns the option being typed in an autocomplete interaction.
+func focusedOption(
+	options []*discordgo.ApplicationCommandInteractionDataOption,
+) *discordgo.ApplicationCommandInteractionDataOption {
+	for _, option := range options {
+		if option.Focused {
+			return option
+		}
+		if focused := focusedOption(option.Options); focused != nil {
+			return focused
+		}
+	}
+	return nil
+}
+
+// handleAutocomplete suggests values for slash command arguments tagged with autocomplete.
+func handleAutocomplete(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	data := interaction.ApplicationCommandData()
+	command, options := slashCommandFor(data)
+	if command == nil {
+		return
+	}
+	option := focusedOption(options)
+	if option == nil {
+		return
+	}
+	field, ok := findArgsFieldForOption(reflect.TypeOf(command.slashHandler).In(2), option.Name)
+	if !ok {
+		return
+	}
+
+	typed := strings.ToLower(fmt.Sprint(option.Value))
+	var matches []*discordgo.ApplicationCommandOptionChoice
+	for _, value := range argChoices(field) {
+		if strings.Contains(strings.ToLower(value), typed) {
+			matches = append(matches, &discordgo.ApplicationCommandOptionChoice{Name: value, Value: value})
+		}
+		if len(matches) == maxOptionChoices {
+			break
+		}
+	}
+
+	err := session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: matches},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send autocomplete results")
+	}
+}
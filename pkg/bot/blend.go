@@ -0,0 +1,76 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// blendModes maps the modes accepted by blend to ImageMagick composite operators.
+var blendModes = map[string]imagick.CompositeOperator{
+	"over":       imagick.COMPOSITE_OP_OVER,
+	"multiply":   imagick.COMPOSITE_OP_MULTIPLY,
+	"screen":     imagick.COMPOSITE_OP_SCREEN,
+	"overlay":    imagick.COMPOSITE_OP_OVERLAY,
+	"darken":     imagick.COMPOSITE_OP_DARKEN,
+	"lighten":    imagick.COMPOSITE_OP_LIGHTEN,
+	"difference": imagick.COMPOSITE_OP_DIFFERENCE,
+	"exclusion":  imagick.COMPOSITE_OP_EXCLUSION,
+	"add":        imagick.COMPOSITE_OP_PLUS,
+	"softlight":  imagick.COMPOSITE_OP_SOFT_LIGHT,
+	"hardlight":  imagick.COMPOSITE_OP_HARD_LIGHT,
+	"colordodge": imagick.COMPOSITE_OP_COLOR_DODGE,
+	"colorburn":  imagick.COMPOSITE_OP_COLOR_BURN,
+	"hue":        imagick.COMPOSITE_OP_HUE,
+	"saturate":   imagick.COMPOSITE_OP_SATURATE,
+	"luminize":   imagick.COMPOSITE_OP_LUMINIZE,
+}
+
+type BlendArgs struct {
+	ImageURL      string  `default:"" cache:"-" description:"URL to the first image, or #N to pick one from a message. Leave blank to auto-find."`
+	OtherImageURL string  `default:"" cache:"-" description:"URL to the second image, or #N to pick one from a message. Leave blank to auto-find."`
+	Mode          string  `default:"over" autocomplete:"blend_modes" description:"How to blend the images, such as over, multiply, screen, overlay or difference."`
+	Opacity       float64 `default:"50" description:"Opacity of the second image, as a percentage."`
+}
+
+func (args BlendArgs) GetImageURLs() []string {
+	return []string{args.ImageURL, args.OtherImageURL}
+}
+
+// Blend composites the second image over the first, stretched to the same size.
+func Blend(_ context.Context, inputs []*imagick.MagickWand, args BlendArgs) ([]*imagick.MagickWand, error) {
+	mode, ok := blendModes[strings.ToLower(args.Mode)]
+	if !ok {
+		modes := slices.Sorted(maps.Keys(blendModes))
+		return nil, newUserError("Unknown blend mode %q. Try one of: %s.", args.Mode, strings.Join(modes, ", "))
+	}
+
+	base := inputs[0]
+	overlay, err := resizeToMatch(inputs[1], base.GetImageWidth(), base.GetImageHeight())
+	if err != nil {
+		return nil, err
+	}
+	defer overlay.Destroy()
+
+	err = overlay.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_ACTIVATE)
+	if err != nil {
+		return nil, fmt.Errorf("error enabling alpha channel: %w", err)
+	}
+	origMask := overlay.SetImageChannelMask(imagick.CHANNEL_ALPHA)
+	err = overlay.EvaluateImage(imagick.EVAL_OP_MULTIPLY, max(0, min(args.Opacity, 100))/100)
+	overlay.SetImageChannelMask(origMask)
+	if err != nil {
+		return nil, fmt.Errorf("error applying opacity: %w", err)
+	}
+
+	err = base.CompositeImage(overlay, mode, true, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error compositing image: %w", err)
+	}
+
+	return []*imagick.MagickWand{base}, nil
+}
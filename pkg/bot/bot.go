@@ -2,6 +2,7 @@ package bot
 
 import (
 	"fmt"
+	"reflect"
 	"slices"
 
 	"github.com/bwmarrin/discordgo"
@@ -9,7 +10,6 @@ import (
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
 	"github.com/rs/zerolog/log"
-	"pkg.nit.so/switchboard"
 
 	configPkg "github.com/fogo-sh/borik/pkg/config"
 )
@@ -18,9 +18,13 @@ import (
 type Bot struct {
 	session      *discordgo.Session
 	openAiClient openai.Client
+	downloader   *Downloader
+	pages        *PageResolver
+	jobs         *JobQueue
+	cache        *ResultCache
 	config       *configPkg.Config
 	textParser   *parsley.Parser
-	slashParser  *switchboard.Switchboard
+	slashEnabled bool
 	quitChan     chan struct{}
 }
 
@@ -47,165 +51,359 @@ func (b *Bot) Stop() {
 // Instance is the current instance of Borik.
 var Instance *Bot
 
+// commandCategory is a group of related commands, listed together in help.
+type commandCategory struct {
+	name        string
+	title       string
+	description string
+	// subcommands registers the category's commands as subcommands of a slash command named after it, rather than
+	// as slash commands of their own. Their text commands keep their own names.
+	subcommands bool
+	// prefix is trimmed from the names of the category's commands to give their subcommand names.
+	prefix string
+}
+
+var (
+	effectsCategory = &commandCategory{
+		name:        "effects",
+		title:       "Effects",
+		description: "Distort and filter an image.",
+	}
+	editCategory = &commandCategory{
+		name:        "edit",
+		title:       "Editing",
+		description: "Resize, rotate, caption and convert images.",
+	}
+	combineCategory = &commandCategory{
+		name:        "combine",
+		title:       "Combining",
+		description: "Combine several images into one.",
+	}
+	overlayCategory = &commandCategory{
+		name:        "overlay",
+		title:       "Overlays",
+		description: "Overlay someone onto an image.",
+		subcommands: true,
+	}
+	frameCategory = &commandCategory{
+		name:        "frame",
+		title:       "Frames",
+		description: "Put an image in a frame.",
+		subcommands: true,
+	}
+	retroCategory = &commandCategory{
+		name:        "retro",
+		title:       "Retro",
+		description: "Convert an image to a retro graphics format.",
+		subcommands: true,
+	}
+	aiCategory = &commandCategory{
+		name:        "ai",
+		title:       "AI",
+		description: "Generate and edit images with AI.",
+		subcommands: true,
+		prefix:      "ai",
+	}
+	utilityCategory = &commandCategory{
+		name:        "utility",
+		title:       "Utilities",
+		description: "Fetch images and manage results.",
+	}
+)
+
+// commandCategories lists every category in the order they're shown in help.
+var commandCategories = []*commandCategory{
+	effectsCategory,
+	editCategory,
+	combineCategory,
+	overlayCategory,
+	frameCategory,
+	retroCategory,
+	aiCategory,
+	utilityCategory,
+}
+
 type Command struct {
 	name         string
 	aliases      []string
 	slashAliases []string
 	description  string
+	category     *commandCategory
+	// examples lists arguments to show the command being run with in its help.
+	examples     []string
 	textHandler  any
 	slashHandler any
+	operation    *registeredOperation
 	enabled      func(*configPkg.Config) bool
 }
 
+// commandNames maps the name and every alias of each enabled command to the command's name.
+var commandNames = map[string]string{}
+
+// enabledCommands contains each enabled command, keyed by its name.
+var enabledCommands = map[string]*Command{}
+
 var commands = []Command{
 	{
 		name:         "magik",
 		slashAliases: []string{"borik"},
 		description:  "Magikify an image.",
+		category:     effectsCategory,
+		examples:     []string{"", "Scale=3", "#2 2", "#all", "^ Scale=0.5"},
 		textHandler:  MakeImageOpTextCommand(Magik),
 		slashHandler: MakeImageOpSlashCommand(Magik),
+		operation:    makeRegisteredOperation(Magik),
 	},
 	{
 		name:         "lagik",
 		description:  "Lagikify an image.",
+		category:     effectsCategory,
 		textHandler:  MakeImageOpTextCommand(Lagik),
 		slashHandler: MakeImageOpSlashCommand(Lagik),
+		operation:    makeRegisteredOperation(Lagik),
 	},
 	{
 		name:         "gmagik",
 		description:  "Repeatedly magikify an image.",
+		category:     effectsCategory,
 		textHandler:  MakeImageOpTextCommand(Gmagik),
 		slashHandler: MakeImageOpSlashCommand(Gmagik),
+		operation:    makeRegisteredOperation(Gmagik),
 	},
 	{
 		name:         "arcweld",
 		description:  "Arc-weld an image.",
+		category:     effectsCategory,
 		textHandler:  MakeImageOpTextCommand(Arcweld),
 		slashHandler: MakeImageOpSlashCommand(Arcweld),
+		operation:    makeRegisteredOperation(Arcweld),
 	},
 	{
 		name:         "malt",
 		description:  "Malt an image.",
+		category:     effectsCategory,
 		textHandler:  MakeImageOpTextCommand(Malt),
 		slashHandler: MakeImageOpSlashCommand(Malt),
+		operation:    makeRegisteredOperation(Malt),
 	},
 	{
 		name:         "help",
 		description:  "Get help for available commands.",
+		category:     utilityCategory,
+		examples:     []string{"", "magik"},
 		textHandler:  HelpCommand,
 		slashHandler: HelpSlashCommand,
 	},
 	{
 		name:         "deepfry",
 		description:  "Deep-fry an image.",
+		category:     effectsCategory,
+		examples:     []string{"", "EdgeRadius=200"},
 		textHandler:  MakeImageOpTextCommand(Deepfry),
 		slashHandler: MakeImageOpSlashCommand(Deepfry),
+		operation:    makeRegisteredOperation(Deepfry),
 	},
 	{
 		name:         "divine",
 		description:  "Sever the divine light.",
+		category:     effectsCategory,
 		textHandler:  MakeImageOpTextCommand(Divine),
 		slashHandler: MakeImageOpSlashCommand(Divine),
+		operation:    makeRegisteredOperation(Divine),
 	},
 	{
 		name:         "waaw",
 		description:  "Mirror the right half of an image.",
+		category:     effectsCategory,
 		textHandler:  MakeImageOpTextCommand(Waaw),
 		slashHandler: MakeImageOpSlashCommand(Waaw),
+		operation:    makeRegisteredOperation(Waaw),
 	},
 	{
 		name:         "haah",
 		description:  "Mirror the left half of an image.",
+		category:     effectsCategory,
 		textHandler:  MakeImageOpTextCommand(Haah),
 		slashHandler: MakeImageOpSlashCommand(Haah),
+		operation:    makeRegisteredOperation(Haah),
 	},
 	{
 		name:         "woow",
 		description:  "Mirror the top half of an image.",
+		category:     effectsCategory,
 		textHandler:  MakeImageOpTextCommand(Woow),
 		slashHandler: MakeImageOpSlashCommand(Woow),
+		operation:    makeRegisteredOperation(Woow),
 	},
 	{
 		name:         "hooh",
 		description:  "Mirror the bottom half of an image.",
+		category:     effectsCategory,
 		textHandler:  MakeImageOpTextCommand(Hooh),
 		slashHandler: MakeImageOpSlashCommand(Hooh),
+		operation:    makeRegisteredOperation(Hooh),
 	},
 	{
 		name:         "invert",
 		description:  "Invert the colours of an image.",
+		category:     effectsCategory,
 		textHandler:  MakeImageOpTextCommand(Invert),
 		slashHandler: MakeImageOpSlashCommand(Invert),
+		operation:    makeRegisteredOperation(Invert),
 	},
 	{
 		name:         "otsu",
 		description:  "Apply a threshold to an image using Otsu's method.",
+		category:     effectsCategory,
 		textHandler:  MakeImageOpTextCommand(Otsu),
 		slashHandler: MakeImageOpSlashCommand(Otsu),
+		operation:    makeRegisteredOperation(Otsu),
 	},
 	{
 		name:         "rotate",
 		description:  "Rotate an image.",
+		category:     editCategory,
+		examples:     []string{"", "Degrees=45"},
 		textHandler:  MakeImageOpTextCommand(Rotate),
 		slashHandler: MakeImageOpSlashCommand(Rotate),
+		operation:    makeRegisteredOperation(Rotate),
+	},
+	{
+		name:         "blend",
+		description:  "Blend one image onto another.",
+		category:     combineCategory,
+		examples:     []string{"", "Mode=multiply Opacity=75"},
+		textHandler:  MakeMultiImageOpTextCommand(Blend),
+		slashHandler: MakeMultiImageOpSlashCommand(Blend),
+	},
+	{
+		name:         "sidebyside",
+		description:  "Place images next to each other.",
+		category:     combineCategory,
+		examples:     []string{"", "Count=3", "#1,#3"},
+		textHandler:  MakeMultiImageOpTextCommand(SideBySide),
+		slashHandler: MakeMultiImageOpSlashCommand(SideBySide),
+	},
+	{
+		name:         "stack",
+		description:  "Stack images on top of each other.",
+		category:     combineCategory,
+		textHandler:  MakeMultiImageOpTextCommand(Stack),
+		slashHandler: MakeMultiImageOpSlashCommand(Stack),
+	},
+	{
+		name:         "diff",
+		description:  "Show the difference between two images.",
+		category:     combineCategory,
+		textHandler:  MakeMultiImageOpTextCommand(Diff),
+		slashHandler: MakeMultiImageOpSlashCommand(Diff),
+	},
+	{
+		name:         "swapfaces-lite",
+		description:  "Paste the centre of one image onto the centre of another.",
+		category:     combineCategory,
+		textHandler:  MakeMultiImageOpTextCommand(SwapFaces),
+		slashHandler: MakeMultiImageOpSlashCommand(SwapFaces),
+	},
+	{
+		name:         "chain",
+		description:  "Run an image through several operations in sequence.",
+		category:     utilityCategory,
+		examples:     []string{`"magik Scale=2 | deepfry | invert"`},
+		textHandler:  ChainTextCommand,
+		slashHandler: ChainSlashCommand,
+	},
+	{
+		name:         "undo",
+		description:  "Go back to the image the last result in this channel was made from.",
+		category:     utilityCategory,
+		textHandler:  UndoCommand,
+		slashHandler: UndoSlashCommand,
+	},
+	{
+		name:         "cancel",
+		description:  "Cancel your jobs that are in progress.",
+		category:     utilityCategory,
+		textHandler:  CancelCommand,
+		slashHandler: CancelSlashCommand,
 	},
 	{
 		name:         "avatar",
 		description:  "Fetch the avatar for a user.",
+		category:     utilityCategory,
+		examples:     []string{"", "@someone"},
 		textHandler:  Avatar,
 		slashHandler: AvatarSlashCommand,
 	},
 	{
 		name:         "sticker",
 		description:  "Fetch a sticker as an image.",
+		category:     utilityCategory,
 		textHandler:  Sticker,
 		slashHandler: nil,
 	},
 	{
 		name:         "emoji",
 		description:  "Fetch an emoji as an image.",
+		category:     utilityCategory,
 		textHandler:  Emoji,
 		slashHandler: nil,
 	},
 	{
 		name:         "resize",
 		description:  "Resize an image.",
+		category:     editCategory,
+		examples:     []string{"50 50", "640 480 Mode=absolute"},
 		textHandler:  MakeImageOpTextCommand(Resize),
 		slashHandler: MakeImageOpSlashCommand(Resize),
+		operation:    makeRegisteredOperation(Resize),
 	},
 	{
 		name:         "huecycle",
 		description:  "Create a GIF cycling the hue of an image.",
+		category:     effectsCategory,
 		textHandler:  MakeImageOpTextCommand(HueCycle),
 		slashHandler: MakeImageOpSlashCommand(HueCycle),
+		operation:    makeRegisteredOperation(HueCycle),
 	},
 	{
 		name:         "gif",
 		description:  "Convert a video to a GIF.",
+		category:     editCategory,
+		examples:     []string{"", "FPS=15 Width=480"},
 		textHandler:  GifTextCommand,
 		slashHandler: GifSlashCommand,
 	},
 	{
 		name:         "modulate",
 		description:  "Modify the brightness, saturation, and hue of an image.",
+		category:     effectsCategory,
+		examples:     []string{"Saturation=200", "Hue=150"},
 		textHandler:  MakeImageOpTextCommand(Modulate),
 		slashHandler: MakeImageOpSlashCommand(Modulate),
+		operation:    makeRegisteredOperation(Modulate),
 	},
 	{
 		name:         "meme",
 		description:  "Add meme text to an image.",
+		category:     editCategory,
+		examples:     []string{`"top text|bottom text"`, `"|just the bottom"`},
 		textHandler:  MakeImageOpTextCommand(Meme),
 		slashHandler: MakeImageOpSlashCommand(Meme),
+		operation:    makeRegisteredOperation(Meme),
 	},
 	{
 		name:         "hdr",
 		description:  "Apply aggressive HDR color boosting to an image.",
+		category:     effectsCategory,
 		textHandler:  MakeImageOpTextCommand(Hdr),
 		slashHandler: MakeImageOpSlashCommand(Hdr),
+		operation:    makeRegisteredOperation(Hdr),
 	},
 	{
 		name:         "aigen",
 		description:  "Generate an image from a prompt.",
+		category:     aiCategory,
 		textHandler:  ImageGenTextCommand,
 		slashHandler: ImageGenSlashCommand,
 		enabled:      func(c *configPkg.Config) bool { return c.OpenaiApiKey != "" },
@@ -213,6 +411,8 @@ var commands = []Command{
 	{
 		name:         "aiedit",
 		description:  "Edit an image based on a prompt.",
+		category:     aiCategory,
+		examples:     []string{`"make it a watercolour painting"`},
 		textHandler:  MakeAIImageOpTextCommand(ImageEdit),
 		slashHandler: MakeAIImageOpSlashCommand(ImageEdit),
 		enabled:      func(c *configPkg.Config) bool { return c.OpenaiApiKey != "" },
@@ -220,6 +420,7 @@ var commands = []Command{
 	{
 		name:         "ailoopedit",
 		description:  "Repeatedly edit an image based on a prompt.",
+		category:     aiCategory,
 		textHandler:  MakeAIImageOpTextCommand(LoopEdit),
 		slashHandler: MakeAIImageOpSlashCommand(LoopEdit),
 		enabled:      func(c *configPkg.Config) bool { return c.OpenaiApiKey != "" },
@@ -227,6 +428,7 @@ var commands = []Command{
 	{
 		name:         "aiflipflop",
 		description:  "Flip-flop between two images, editing each based on a prompt.",
+		category:     aiCategory,
 		textHandler:  MakeAIImageOpTextCommand(FlipFlop),
 		slashHandler: MakeAIImageOpSlashCommand(FlipFlop),
 		enabled:      func(c *configPkg.Config) bool { return c.OpenaiApiKey != "" },
@@ -234,6 +436,7 @@ var commands = []Command{
 	{
 		name:         "aizoom",
 		description:  "Zoom out from an image.",
+		category:     aiCategory,
 		textHandler:  MakeAIImageOpTextCommand(AiZoom),
 		slashHandler: MakeAIImageOpSlashCommand(AiZoom),
 		enabled:      func(c *configPkg.Config) bool { return c.OpenaiApiKey != "" },
@@ -241,6 +444,7 @@ var commands = []Command{
 	{
 		name:         "ailoopzoom",
 		description:  "Repeatedly zoom out from an image.",
+		category:     aiCategory,
 		textHandler:  MakeAIImageOpTextCommand(AiLoopZoom),
 		slashHandler: MakeAIImageOpSlashCommand(AiLoopZoom),
 		enabled:      func(c *configPkg.Config) bool { return c.OpenaiApiKey != "" },
@@ -251,6 +455,8 @@ var commands = []Command{
 func New() (*Bot, error) {
 	config := configPkg.Instance
 
+	configureResourceLimits(config)
+
 	openAiClient := openai.NewClient(
 		option.WithBaseURL(config.OpenaiBaseUrl),
 		option.WithAPIKey(config.OpenaiApiKey),
@@ -266,27 +472,20 @@ func New() (*Bot, error) {
 
 	log.Debug().Msg("Creating text command parser")
 	textParser := parsley.New(config.Prefixes...)
-	textParser.RegisterHandler(session)
+	session.AddHandler(makeTextCommandHandler(textParser))
 	log.Debug().Msg("Text command parser created")
 
 	slashEnabled := config.GuildId != "" || config.RegisterSlashCommandsGlobally
-	var slashParser *switchboard.Switchboard
 	if slashEnabled {
 		if config.AppId == "" {
 			return nil, fmt.Errorf("app ID must be set when slash commands are enabled")
 		}
 
-		log.Debug().Msg("Creating slash command parser")
-		slashParser = &switchboard.Switchboard{}
-		session.AddHandler(slashParser.HandleInteractionCreate)
-
 		if config.RegisterSlashCommandsGlobally {
 			log.Info().Msg("Slash commands will be registered globally")
 		} else {
 			log.Info().Str("guild_id", config.GuildId).Msg("Slash commands will be registered for guild")
 		}
-
-		log.Debug().Msg("Slash command parser created")
 	} else {
 		log.Warn().Msg("Guild ID not set and global registration disabled; skipping registration of slash commands")
 	}
@@ -317,52 +516,52 @@ func New() (*Bot, error) {
 			continue
 		}
 
+		enabledCommands[command.name] = &command
+		commandNames[command.name] = command.name
+		for _, alias := range slices.Concat(command.aliases, command.slashAliases) {
+			commandNames[alias] = command.name
+		}
+
+		for _, name := range slices.Concat([]string{command.name}, command.aliases) {
+			commandArgsTypes[name] = reflect.TypeOf(command.textHandler).In(1)
+		}
+
+		if command.operation != nil {
+			// Only operations that can run with their default arguments can be chained from a result's menu.
+			if _, err := command.operation.parseArgs(nil); err == nil {
+				chainMenuOperations = append(chainMenuOperations, command.name)
+			}
+			operationRegistry[command.name] = command.operation
+			for _, alias := range command.aliases {
+				operationRegistry[alias] = command.operation
+			}
+		}
+
 		_ = textParser.NewCommand(
 			command.name,
 			command.description,
 			command.textHandler,
 		)
 
-		if slashParser != nil && command.slashHandler != nil {
-			_ = slashParser.AddCommand(&switchboard.Command{
-				Name:        command.name,
-				Description: command.description,
-				Handler:     command.slashHandler,
-				GuildID:     slashGuildId,
-			})
-		}
-
 		for _, alias := range command.aliases {
 			_ = textParser.NewCommand(
 				alias,
 				command.description,
 				command.textHandler,
 			)
-
-			if slashParser != nil && command.slashHandler != nil {
-				_ = slashParser.AddCommand(&switchboard.Command{
-					Name:        alias,
-					Description: command.description,
-					Handler:     command.slashHandler,
-					GuildID:     slashGuildId,
-				})
-			}
 		}
 
-		if slashParser != nil && command.slashHandler != nil {
-			for _, alias := range command.slashAliases {
-				_ = slashParser.AddCommand(&switchboard.Command{
-					Name:        alias,
-					Description: command.description,
-					Handler:     command.slashHandler,
-					GuildID:     slashGuildId,
-				})
+		if slashEnabled && command.slashHandler != nil {
+			if command.category != nil && command.category.subcommands {
+				addSlashSubcommands(&command)
+			} else {
+				addSlashCommand(&command)
 			}
 		}
 	}
 
-	if slashParser != nil {
-		err = slashParser.SyncCommands(session, config.AppId)
+	if slashEnabled {
+		err = syncApplicationCommands(session, config.AppId, slashGuildId, config.ContextMenuCommands)
 		if err != nil {
 			return nil, fmt.Errorf("error syncing commands: %w", err)
 		}
@@ -370,12 +569,24 @@ func New() (*Bot, error) {
 
 	log.Debug().Msg("Commands registered")
 
+	session.AddHandler(handleInteraction)
+
+	downloader := NewDownloader(config)
+	var pages *PageResolver
+	if config.ResolvePageMedia {
+		pages = NewPageResolver(downloader, config.MaxPageSize)
+	}
+
 	Instance = &Bot{
 		session,
 		openAiClient,
+		downloader,
+		pages,
+		NewJobQueue(config),
+		NewResultCache(config),
 		config,
 		textParser,
-		slashParser,
+		slashEnabled,
 		make(chan struct{}),
 	}
 
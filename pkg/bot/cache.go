@@ -0,0 +1,309 @@
+package bot
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	configPkg "github.com/fogo-sh/borik/pkg/config"
+)
+
+// SeededOperationArgs is implemented by the arguments of operations that produce random results.
+// Their results are only cached when an explicit seed is provided.
+type SeededOperationArgs interface {
+	GetSeed() int
+}
+
+// CacheableOperationArgs is implemented by arguments that decide for themselves whether their results can be cached.
+type CacheableOperationArgs interface {
+	Cacheable() bool
+}
+
+// isCacheable reports whether the result of running an operation with the given arguments can be reused.
+func isCacheable(args any) bool {
+	switch args := args.(type) {
+	case CacheableOperationArgs:
+		return args.Cacheable()
+	case SeededOperationArgs:
+		return args.GetSeed() != 0
+	default:
+		return true
+	}
+}
+
+// normaliseArgs encodes an operation's arguments for use in a cache key.
+// Fields tagged with cache:"-", such as media URLs, are omitted, as the cache is keyed on the content of the media
+// instead.
+func normaliseArgs(args any) ([]byte, error) {
+	if marshaler, ok := args.(json.Marshaler); ok {
+		return marshaler.MarshalJSON()
+	}
+
+	value := reflect.ValueOf(args)
+	normalised := reflect.New(value.Type()).Elem()
+	normalised.Set(value)
+
+	if normalised.Kind() == reflect.Struct {
+		for index := 0; index < normalised.NumField(); index++ {
+			if normalised.Type().Field(index).Tag.Get("cache") == "-" {
+				field := normalised.Field(index)
+				field.Set(reflect.Zero(field.Type()))
+			}
+		}
+	}
+
+	return json.Marshal(normalised.Interface())
+}
+
+// resultCacheKey computes the key under which the result of an operation is cached.
+func resultCacheKey(
+	input []byte,
+	command string,
+	args any,
+	uploadLimit int64,
+	outputFormat string,
+) (string, error) {
+	normalisedArgs, err := normaliseArgs(args)
+	if err != nil {
+		return "", fmt.Errorf("error normalising arguments: %w", err)
+	}
+
+	inputHash := sha256.Sum256(input)
+
+	hash := sha256.New()
+	_, _ = fmt.Fprintf(
+		hash,
+		"%x\x00%s\x00%s\x00%d\x00%s",
+		inputHash,
+		command,
+		normalisedArgs,
+		uploadLimit,
+		outputFormat,
+	)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// cachedResult is the encoded result of an operation, as it was uploaded.
+type cachedResult struct {
+	Format  string
+	Content string
+	Blob    []byte
+}
+
+func (r *cachedResult) size() int64 {
+	return int64(len(r.Blob) + len(r.Format) + len(r.Content))
+}
+
+type cacheEntry struct {
+	key    string
+	result *cachedResult
+}
+
+// ResultCache stores the results of operations, keeping the most recently used results in memory
+// and optionally persisting every result to disk.
+type ResultCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	size    int64
+	maxSize int64
+
+	dir    string
+	maxAge time.Duration
+}
+
+// NewResultCache constructs a new ResultCache based on the provided config.
+func NewResultCache(config *configPkg.Config) *ResultCache {
+	cache := &ResultCache{
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+		maxSize: config.CacheSize,
+		dir:     config.CacheDir,
+		maxAge:  config.CacheMaxAge,
+	}
+
+	if cache.dir != "" {
+		if err := os.MkdirAll(cache.dir, 0o755); err != nil {
+			log.Error().Err(err).Str("dir", cache.dir).Msg("Failed to create cache directory; disabling disk cache")
+			cache.dir = ""
+		} else {
+			go cache.pruneDisk()
+		}
+	}
+
+	return cache
+}
+
+// enabled reports whether the cache will store anything.
+func (c *ResultCache) enabled() bool {
+	return c.maxSize > 0 || c.dir != ""
+}
+
+// Get returns the cached result for a key, if there is one.
+func (c *ResultCache) Get(key string) (*cachedResult, bool) {
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		c.mu.Unlock()
+		return element.Value.(*cacheEntry).result, true
+	}
+	c.mu.Unlock()
+
+	if c.dir == "" {
+		return nil, false
+	}
+
+	result, err := c.readDisk(key)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn().Err(err).Str("key", key).Msg("Failed to read cached result from disk")
+		}
+		return nil, false
+	}
+
+	c.putMemory(key, result)
+	return result, true
+}
+
+// Put stores the result for a key.
+func (c *ResultCache) Put(key string, result *cachedResult) {
+	c.putMemory(key, result)
+
+	if c.dir != "" {
+		if err := c.writeDisk(key, result); err != nil {
+			log.Warn().Err(err).Str("key", key).Msg("Failed to write cached result to disk")
+		}
+	}
+}
+
+func (c *ResultCache) putMemory(key string, result *cachedResult) {
+	if result.size() > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.size -= element.Value.(*cacheEntry).result.size()
+		element.Value.(*cacheEntry).result = result
+		c.order.MoveToFront(element)
+	} else {
+		c.entries[key] = c.order.PushFront(&cacheEntry{key: key, result: result})
+	}
+	c.size += result.size()
+
+	for c.size > c.maxSize {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*cacheEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, entry.key)
+		c.size -= entry.result.size()
+	}
+}
+
+func (c *ResultCache) diskPath(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+func (c *ResultCache) readDisk(key string) (*cachedResult, error) {
+	path := c.diskPath(key)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if c.maxAge > 0 && time.Since(info.ModTime()) > c.maxAge {
+		c.removeDisk(path)
+		return nil, os.ErrNotExist
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result cachedResult
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding cached result: %w", err)
+	}
+	return &result, nil
+}
+
+func (c *ResultCache) writeDisk(key string, result *cachedResult) error {
+	path := c.diskPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating cache directory: %w", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(result); err != nil {
+		return fmt.Errorf("error encoding result: %w", err)
+	}
+
+	// Write to a temporary file first so that a partially written result is never read.
+	tempFile, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary cache file: %w", err)
+	}
+	tempPath := tempFile.Name()
+
+	if _, err := tempFile.Write(buffer.Bytes()); err != nil {
+		_ = tempFile.Close()
+		c.removeDisk(tempPath)
+		return fmt.Errorf("error writing cache file: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		c.removeDisk(tempPath)
+		return fmt.Errorf("error closing cache file: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		c.removeDisk(tempPath)
+		return fmt.Errorf("error renaming cache file: %w", err)
+	}
+
+	return nil
+}
+
+func (c *ResultCache) removeDisk(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Str("path", path).Msg("Failed to remove cache file")
+	}
+}
+
+// pruneDisk removes results from the disk cache that are older than the maximum age.
+func (c *ResultCache) pruneDisk() {
+	if c.maxAge <= 0 {
+		return
+	}
+
+	err := filepath.WalkDir(c.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if time.Since(info.ModTime()) > c.maxAge {
+			c.removeDisk(path)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to prune disk cache")
+	}
+}
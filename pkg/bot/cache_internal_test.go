@@ -0,0 +1,119 @@
+package bot
+
+import (
+	"testing"
+)
+
+// mustCacheKey computes a cache key, failing the test if it can't be.
+func mustCacheKey(t *testing.T, input string, command string, args any) string {
+	t.Helper()
+	key, err := resultCacheKey([]byte(input), command, args, 8*1024*1024, "gif")
+	if err != nil {
+		t.Fatalf("unexpected error computing cache key: %v", err)
+	}
+	return key
+}
+
+// mustPipeline parses a pipeline definition, failing the test if it's invalid.
+func mustPipeline(t *testing.T, definition string) pipelineArgs {
+	t.Helper()
+	stages, err := parsePipelineString(definition)
+	if err != nil {
+		t.Fatalf("unexpected error parsing pipeline %q: %v", definition, err)
+	}
+	return pipelineArgs{stages: stages}
+}
+
+func TestResultCacheKeyIgnoresMediaURLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		a, b    any
+	}{
+		{
+			name:    "image URL",
+			command: "magik",
+			a:       MagikArgs{ImageURL: "https://example.com/a.png", Scale: 2},
+			b:       MagikArgs{ImageURL: "https://example.com/b.png", Scale: 2},
+		},
+		{
+			name:    "second image URL",
+			command: "blend",
+			a:       BlendArgs{ImageURL: "a", OtherImageURL: "b", Mode: "over", Opacity: 50},
+			b:       BlendArgs{ImageURL: "c", OtherImageURL: "d", Mode: "over", Opacity: 50},
+		},
+		{
+			name:    "image URL list",
+			command: "sidebyside",
+			a:       CombineArgs{ImageURLs: "a,b", Count: 2},
+			b:       CombineArgs{ImageURLs: "c,d", Count: 2},
+		},
+		{
+			name:    "video URL",
+			command: "gif",
+			a:       GifArgs{VideoURL: "https://example.com/a.mp4", FPS: 10},
+			b:       GifArgs{VideoURL: "https://example.com/b.mp4", FPS: 10},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if mustCacheKey(t, "input", test.command, test.a) != mustCacheKey(t, "input", test.command, test.b) {
+				t.Error("keys differ for arguments that only differ by media URL")
+			}
+		})
+	}
+}
+
+func TestResultCacheKeyDistinguishesInputs(t *testing.T) {
+	base := mustCacheKey(t, "input", "magik", MagikArgs{Scale: 2})
+
+	if mustCacheKey(t, "input", "magik", MagikArgs{Scale: 2}) != base {
+		t.Error("the same inputs produced different keys")
+	}
+	if mustCacheKey(t, "other input", "magik", MagikArgs{Scale: 2}) == base {
+		t.Error("different input content produced the same key")
+	}
+	if mustCacheKey(t, "input", "lagik", MagikArgs{Scale: 2}) == base {
+		t.Error("different commands produced the same key")
+	}
+	if mustCacheKey(t, "input", "magik", MagikArgs{Scale: 3}) == base {
+		t.Error("different arguments produced the same key")
+	}
+}
+
+func TestResultCacheKeySeededArgs(t *testing.T) {
+	first := mustCacheKey(t, "input", "aiedit", ImageEditArgs{Prompt: "cat", Seed: 1})
+
+	if mustCacheKey(t, "input", "aiedit", ImageEditArgs{Prompt: "cat", Seed: 2}) == first {
+		t.Error("different seeds produced the same key")
+	}
+	if mustCacheKey(t, "input", "aiedit", ImageEditArgs{Prompt: "dog", Seed: 1}) == first {
+		t.Error("different prompts produced the same key")
+	}
+	if isCacheable(ImageEditArgs{Prompt: "cat"}) {
+		t.Error("results without an explicit seed should not be cacheable")
+	}
+	if !isCacheable(ImageEditArgs{Prompt: "cat", Seed: 1}) {
+		t.Error("results with an explicit seed should be cacheable")
+	}
+}
+
+func TestResultCacheKeyPipelines(t *testing.T) {
+	registerTestOperations(t)
+
+	base := mustCacheKey(t, "input", "chain", mustPipeline(t, "magik Scale=2 | meme hello"))
+
+	if mustCacheKey(t, "input", "chain", mustPipeline(t, "magik Scale=2 | meme hello")) != base {
+		t.Error("the same pipeline produced different keys")
+	}
+	if mustCacheKey(t, "input", "chain", mustPipeline(t, "magik https://example.com/a.png Scale=2 | meme hello")) != base {
+		t.Error("pipelines that only differ by image URL produced different keys")
+	}
+	if mustCacheKey(t, "input", "chain", mustPipeline(t, "magik Scale=3 | meme hello")) == base {
+		t.Error("pipelines with different arguments produced the same key")
+	}
+	if mustCacheKey(t, "input", "chain", mustPipeline(t, "meme hello | magik Scale=2")) == base {
+		t.Error("pipelines with stages in a different order produced the same key")
+	}
+}
@@ -0,0 +1,175 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+var errJobCancelled = newUserError("Cancelled.")
+
+// activeJob is a job that has been started by a user, and which may still be waiting in the queue.
+type activeJob struct {
+	cancel context.CancelCauseFunc
+}
+
+// jobRegistry tracks the jobs each user has in progress, so that they can be cancelled.
+type jobRegistry struct {
+	mu   sync.Mutex
+	jobs map[string]map[*activeJob]struct{}
+}
+
+var activeJobs = &jobRegistry{jobs: map[string]map[*activeJob]struct{}{}}
+
+// add registers a job for a user, returning a function that removes it again.
+func (r *jobRegistry) add(userID string, cancel context.CancelCauseFunc) func() {
+	job := &activeJob{cancel: cancel}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.jobs[userID] == nil {
+		r.jobs[userID] = map[*activeJob]struct{}{}
+	}
+	r.jobs[userID][job] = struct{}{}
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		delete(r.jobs[userID], job)
+		if len(r.jobs[userID]) == 0 {
+			delete(r.jobs, userID)
+		}
+	}
+}
+
+// cancelAll cancels every job a user has in progress, returning how many were cancelled.
+func (r *jobRegistry) cancelAll(userID string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for job := range r.jobs[userID] {
+		job.cancel(errJobCancelled)
+	}
+	return len(r.jobs[userID])
+}
+
+// commandTimeout returns how long the named command may run before it is abandoned.
+func commandTimeout(command string) time.Duration {
+	if timeout, ok := Instance.config.CommandTimeouts[command]; ok {
+		return timeout
+	}
+	return Instance.config.OperationTimeout
+}
+
+// StartJob waits for this operation's turn in the job queue, then sets up the context it runs under,
+// applying the command's timeout, allowing the requester to cancel it, and reporting its progress.
+// The returned function must be called once the operation has finished.
+func (ctx *OperationContext) StartJob() (func(), error) {
+	jobCtx, cancel := context.WithCancelCause(context.Background())
+	unregister := activeJobs.add(ctx.GetUserID(), cancel)
+
+	release, err := ctx.WaitForJobSlot(jobCtx)
+	if err != nil {
+		unregister()
+		cancel(nil)
+		return nil, err
+	}
+
+	stopTimeout := context.CancelFunc(func() {})
+	if timeout := commandTimeout(ctx.GetCommandName()); timeout > 0 {
+		jobCtx, stopTimeout = context.WithTimeoutCause(
+			jobCtx,
+			timeout,
+			newUserError("That took too long, so I gave up after %s.", timeout),
+		)
+	}
+	progress := newProgressReporter(ctx)
+	work := &sync.WaitGroup{}
+	ctx.jobCtx = withJobWork(withProgressReporter(jobCtx, progress), work)
+
+	return func() {
+		progress.Finish()
+		stopTimeout()
+		unregister()
+		cancel(nil)
+
+		// Work left running after the job was cancelled or timed out keeps the job's slot until it returns, so that
+		// cancelling and retrying can't run more at once than the queue allows.
+		go func() {
+			work.Wait()
+			release()
+		}()
+	}, nil
+}
+
+type jobWorkKey struct{}
+
+// withJobWork attaches the WaitGroup tracking the background work of a job to ctx.
+func withJobWork(ctx context.Context, work *sync.WaitGroup) context.Context {
+	return context.WithValue(ctx, jobWorkKey{}, work)
+}
+
+// runUntilDone runs fn in the background, returning early with the cause of ctx being done if that happens first.
+// Calls into ImageMagick cannot be interrupted, so fn is left to finish on its own in that case, and is tracked as
+// work of the job running under ctx until it does.
+func runUntilDone[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+
+	work, tracked := ctx.Value(jobWorkKey{}).(*sync.WaitGroup)
+	if tracked {
+		work.Add(1)
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		if tracked {
+			defer work.Done()
+		}
+		value, err := fn()
+		done <- result{value, err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.value, result.err
+	case <-ctx.Done():
+		var zero T
+		return zero, context.Cause(ctx)
+	}
+}
+
+type CancelArgs struct{}
+
+func cancelJobs(ctx *OperationContext) {
+	var content string
+	switch cancelled := activeJobs.cancelAll(ctx.GetUserID()); cancelled {
+	case 0:
+		content = "You don't have any jobs in progress."
+	case 1:
+		content = "Cancelling your job."
+	default:
+		content = fmt.Sprintf("Cancelling your %d jobs.", cancelled)
+	}
+
+	if err := ctx.SendText(content); err != nil {
+		log.Error().Err(err).Msg("Failed to send cancellation message")
+	}
+}
+
+// CancelCommand cancels all of the requester's jobs that are in progress.
+func CancelCommand(message *discordgo.MessageCreate, _ CancelArgs) {
+	cancelJobs(NewOperationContextFromMessage(Instance.session, message))
+}
+
+func CancelSlashCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate, _ CancelArgs) {
+	cancelJobs(NewOperationContextFromInteraction(session, interaction))
+}
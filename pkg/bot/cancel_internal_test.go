@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunUntilDoneReturnsResult(t *testing.T) {
+	value, err := runUntilDone(context.Background(), func() (int, error) { return 42, nil })
+	if err != nil || value != 42 {
+		t.Fatalf("got %d, %v, want 42, nil", value, err)
+	}
+}
+
+func TestRunUntilDoneTracksAbandonedWork(t *testing.T) {
+	work := &sync.WaitGroup{}
+	cause := errors.New("cancelled")
+	ctx, cancel := context.WithCancelCause(withJobWork(context.Background(), work))
+
+	unblock := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		<-started
+		cancel(cause)
+	}()
+
+	_, err := runUntilDone(ctx, func() (int, error) {
+		close(started)
+		<-unblock
+		return 0, nil
+	})
+	if !errors.Is(err, cause) {
+		t.Fatalf("got error %v, want the cause of cancellation", err)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		work.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		t.Fatal("the job's work finished while the abandoned function was still running")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(unblock)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the abandoned function to be counted as finished")
+	}
+}
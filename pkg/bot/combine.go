@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// maxCombinedImages is the most images that sidebyside and stack will combine at once.
+const maxCombinedImages = 10
+
+type CombineArgs struct {
+	ImageURLs string `default:"" cache:"-" description:"Comma-separated URLs or picks like #1,#3. Leave blank to automatically attempt to find images."`
+	Count     uint   `default:"2" description:"Number of images to combine."`
+}
+
+// GetImageURLs returns an entry for each image to combine, up to maxCombinedImages. Any URLs beyond that are ignored.
+func (args CombineArgs) GetImageURLs() []string {
+	urls := splitImageURLs(args.ImageURLs, max(2, min(args.Count, maxCombinedImages)))
+	return urls[:min(len(urls), maxCombinedImages)]
+}
+
+// combineImages joins images into a single row or column, scaling each to match the first along the shared edge.
+func combineImages(inputs []*imagick.MagickWand, vertical bool) ([]*imagick.MagickWand, error) {
+	combined := imagick.NewMagickWand()
+	defer combined.Destroy()
+
+	for _, input := range inputs {
+		width, height := input.GetImageWidth(), input.GetImageHeight()
+		if vertical {
+			height = max(1, uint(float64(height)*float64(inputs[0].GetImageWidth())/float64(width)))
+			width = inputs[0].GetImageWidth()
+		} else {
+			width = max(1, uint(float64(width)*float64(inputs[0].GetImageHeight())/float64(height)))
+			height = inputs[0].GetImageHeight()
+		}
+
+		resized, err := resizeToMatch(input, width, height)
+		if err != nil {
+			return nil, err
+		}
+		err = resized.ResetImagePage("0x0+0+0")
+		if err == nil {
+			err = combined.AddImage(resized)
+		}
+		resized.Destroy()
+		if err != nil {
+			return nil, fmt.Errorf("error adding image: %w", err)
+		}
+	}
+
+	combined.ResetIterator()
+	return []*imagick.MagickWand{combined.AppendImages(vertical)}, nil
+}
+
+// SideBySide places images next to each other, left to right.
+func SideBySide(_ context.Context, inputs []*imagick.MagickWand, _ CombineArgs) ([]*imagick.MagickWand, error) {
+	return combineImages(inputs, false)
+}
+
+// Stack places images on top of each other, top to bottom.
+func Stack(_ context.Context, inputs []*imagick.MagickWand, _ CombineArgs) ([]*imagick.MagickWand, error) {
+	return combineImages(inputs, true)
+}
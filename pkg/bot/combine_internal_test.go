@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCombineArgsGetImageURLs(t *testing.T) {
+	many := make([]string, maxCombinedImages+3)
+	for index := range many {
+		many[index] = string(rune('a' + index))
+	}
+
+	tests := []struct {
+		name string
+		args CombineArgs
+		want []string
+	}{
+		{name: "blank", args: CombineArgs{Count: 2}, want: []string{"", ""}},
+		{name: "padded to count", args: CombineArgs{ImageURLs: "a", Count: 3}, want: []string{"a", "", ""}},
+		{name: "at least two", args: CombineArgs{Count: 0}, want: []string{"", ""}},
+		{
+			name: "count capped",
+			args: CombineArgs{Count: maxCombinedImages + 5},
+			want: make([]string, maxCombinedImages),
+		},
+		{
+			name: "more URLs than count",
+			args: CombineArgs{ImageURLs: "a, b, c", Count: 2},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "URLs capped",
+			args: CombineArgs{ImageURLs: strings.Join(many, ","), Count: 2},
+			want: many[:maxCombinedImages],
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.args.GetImageURLs(); !slices.Equal(got, test.want) {
+				t.Errorf("GetImageURLs() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
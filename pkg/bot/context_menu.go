@@ -0,0 +1,247 @@
+package bot
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// contextMenuPrefix prefixes the custom ID of the modals shown by context menu commands.
+const contextMenuPrefix = "contextmenu"
+
+// maxModalInputs is the most text inputs Discord allows in a modal.
+const maxModalInputs = 5
+
+// maxContextMenuCommands is the most message context menu commands Discord allows an application to have.
+const maxContextMenuCommands = 5
+
+// contextMenuCommand runs a command on the media of the message it was used on.
+type contextMenuCommand struct {
+	command  string
+	argsType reflect.Type
+	run      func(ctx *OperationContext, args reflect.Value, mediaURL string)
+}
+
+// contextMenuCommands contains the registered context menu commands, keyed by the name shown in Discord's menu.
+var contextMenuCommands = map[string]*contextMenuCommand{}
+
+// contextMenuRunners run the commands that aren't image operations but can still be used from a context menu.
+var contextMenuRunners = map[string]*contextMenuCommand{
+	"gif": {
+		argsType: reflect.TypeFor[GifArgs](),
+		run: func(ctx *OperationContext, args reflect.Value, mediaURL string) {
+			gifArgs := args.Interface().(GifArgs)
+			gifArgs.VideoURL = mediaURL
+			PrepareAndInvokeGif(ctx, gifArgs)
+		},
+	},
+}
+
+// contextMenuName returns the name shown in Discord's menu for a command, such as "Magik this".
+func contextMenuName(command string) string {
+	return strings.ToUpper(command[:1]) + command[1:] + " this"
+}
+
+// newContextMenuCommand looks up how to run a command from a context menu, returning nil if it can't be.
+func newContextMenuCommand(command string) *contextMenuCommand {
+	if operation, ok := operationRegistry[command]; ok {
+		return &contextMenuCommand{
+			command:  command,
+			argsType: operation.argsType,
+			run: func(ctx *OperationContext, args reflect.Value, mediaURL string) {
+				ctx.sourceURL = mediaURL
+				PrepareAndInvokeOperation(ctx, args.Interface().(ImageOperationArgs), operation.run)
+			},
+		}
+	}
+
+	if runner, ok := contextMenuRunners[command]; ok {
+		return &contextMenuCommand{command: command, argsType: runner.argsType, run: runner.run}
+	}
+
+	return nil
+}
+
+// contextMenuCommandDefinitions builds a message context menu command for each of the named commands that can be
+// run from one.
+func contextMenuCommandDefinitions(names []string) []*discordgo.ApplicationCommand {
+	var commands []*discordgo.ApplicationCommand
+	for _, name := range names {
+		command, ok := commandNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			log.Warn().Str("command", name).Msg("Skipping unknown context menu command")
+			continue
+		}
+
+		menuCommand := newContextMenuCommand(command)
+		if menuCommand == nil {
+			log.Warn().Str("command", command).Msg("Skipping command that can't be used from a context menu")
+			continue
+		}
+		if len(requiredArgsFields(menuCommand.argsType)) > maxModalInputs {
+			log.Warn().Str("command", command).Msg("Skipping command with too many required arguments for a modal")
+			continue
+		}
+
+		if len(contextMenuCommands) == maxContextMenuCommands {
+			log.Warn().Str("command", command).Msgf("Skipping context menu command beyond the first %d", maxContextMenuCommands)
+			continue
+		}
+
+		menuName := contextMenuName(command)
+		commands = append(commands, &discordgo.ApplicationCommand{
+			Type: discordgo.MessageApplicationCommand,
+			Name: menuName,
+		})
+		contextMenuCommands[menuName] = menuCommand
+	}
+
+	return commands
+}
+
+// requiredArgsFields returns the fields of an argument struct that have no default value.
+func requiredArgsFields(argsType reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for index := 0; index < argsType.NumField(); index++ {
+		field := argsType.Field(index)
+		if _, hasDefault := field.Tag.Lookup("default"); !hasDefault {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// handleContextMenuCommand handles message context menu commands, asking for any arguments they need with a modal.
+func handleContextMenuCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	data := interaction.ApplicationCommandData()
+	menuCommand, ok := contextMenuCommands[data.Name]
+	if !ok {
+		return
+	}
+
+	ctx := NewOperationContextFromInteraction(session, interaction)
+	ctx.commandName = menuCommand.command
+
+	var target *discordgo.Message
+	if data.Resolved != nil {
+		target = data.Resolved.Messages[data.TargetID]
+	}
+	if target == nil {
+		ctx.ReportError(newUserError("I couldn't see the message you picked."))
+		return
+	}
+
+	if required := requiredArgsFields(menuCommand.argsType); len(required) > 0 {
+		showContextMenuModal(ctx, menuCommand, target.ID, required)
+		return
+	}
+	runContextMenuCommand(ctx, menuCommand, target, nil)
+}
+
+// handleContextMenuModal runs a context menu command with the arguments entered in the modal it showed.
+func handleContextMenuModal(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	data := interaction.ModalSubmitData()
+	parts := strings.Split(data.CustomID, ":")
+	if len(parts) != 3 {
+		return
+	}
+
+	ctx := NewOperationContextFromInteraction(session, interaction)
+	ctx.commandName = parts[1]
+
+	menuCommand := newContextMenuCommand(parts[1])
+	if menuCommand == nil {
+		ctx.ReportError(newUserError("I don't know the command %s.", parts[1]))
+		return
+	}
+
+	target, err := session.ChannelMessage(interaction.ChannelID, parts[2])
+	if err != nil {
+		ctx.ReportError(withUserMessage(
+			fmt.Errorf("error fetching target message: %w", err),
+			"I couldn't fetch the message you picked.",
+		))
+		return
+	}
+
+	var arguments []string
+	for _, input := range modalTextInputs(data.Components) {
+		arguments = append(arguments, input.CustomID+"="+input.Value)
+	}
+	runContextMenuCommand(ctx, menuCommand, target, arguments)
+}
+
+// showContextMenuModal asks for the arguments a command can't run without.
+func showContextMenuModal(
+	ctx *OperationContext,
+	menuCommand *contextMenuCommand,
+	targetID string,
+	required []reflect.StructField,
+) {
+	var rows []discordgo.MessageComponent
+	for _, field := range required {
+		rows = append(rows, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.TextInput{
+					CustomID:    field.Name,
+					Label:       field.Name,
+					Style:       discordgo.TextInputShort,
+					Placeholder: field.Tag.Get("description"),
+				},
+			},
+		})
+	}
+
+	err := ctx.Session.InteractionRespond(ctx.Interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID:   strings.Join([]string{contextMenuPrefix, menuCommand.command, targetID}, ":"),
+			Title:      contextMenuName(menuCommand.command),
+			Components: rows,
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to show context menu modal")
+	}
+}
+
+// modalTextInputs returns every text input in the components of a submitted modal.
+func modalTextInputs(components []discordgo.MessageComponent) []*discordgo.TextInput {
+	var inputs []*discordgo.TextInput
+	for _, component := range components {
+		switch c := component.(type) {
+		case *discordgo.TextInput:
+			inputs = append(inputs, c)
+		case *discordgo.ActionsRow:
+			inputs = append(inputs, modalTextInputs(c.Components)...)
+		case *discordgo.Label:
+			inputs = append(inputs, modalTextInputs([]discordgo.MessageComponent{c.Component})...)
+		}
+	}
+	return inputs
+}
+
+// runContextMenuCommand runs a command on the media of a message, with the given text arguments.
+func runContextMenuCommand(
+	ctx *OperationContext,
+	menuCommand *contextMenuCommand,
+	target *discordgo.Message,
+	arguments []string,
+) {
+	mediaURL := mediaURLFromMessage(target, visualMediaType)
+	if mediaURL == "" {
+		ctx.ReportError(newUserError("That message doesn't have an image or video in it."))
+		return
+	}
+
+	args, err := parseArgsStruct(menuCommand.argsType, arguments)
+	if err != nil {
+		ctx.ReportError(&UserError{Message: fmt.Sprintf("I couldn't use those arguments: %s.", err), Err: err})
+		return
+	}
+
+	menuCommand.run(ctx, args, mediaURL)
+}
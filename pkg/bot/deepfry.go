@@ -1,14 +1,15 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
 
 type DeepfryArgs struct {
-	ImageURL        string  `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
-	EdgeRadius      float64 `default:"100" description:"Radius of outline to draw around edges."`
+	ImageURL        string  `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
+	EdgeRadius      float64 `default:"100" primary:"true" description:"Radius of outline to draw around edges."`
 	DownscaleFactor uint    `default:"2" description:"Factor to downscale the image by while processing."`
 }
 
@@ -17,7 +18,7 @@ func (args DeepfryArgs) GetImageURL() string {
 }
 
 // Deepfry destroys an image via a combination of operations.
-func Deepfry(wand *imagick.MagickWand, args DeepfryArgs) ([]*imagick.MagickWand, error) {
+func Deepfry(_ context.Context, wand *imagick.MagickWand, args DeepfryArgs) ([]*imagick.MagickWand, error) {
 	err := wand.ResizeImage(
 		wand.GetImageWidth()/args.DownscaleFactor,
 		wand.GetImageHeight()/args.DownscaleFactor,
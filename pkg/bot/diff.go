@@ -0,0 +1,43 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+type DiffArgs struct {
+	ImageURL      string `default:"" cache:"-" description:"URL to the first image, or #N to pick one from a message. Leave blank to auto-find."`
+	OtherImageURL string `default:"" cache:"-" description:"URL to the second image, or #N to pick one from a message. Leave blank to auto-find."`
+	Highlight     bool   `default:"false" description:"Highlight the pixels that differ, instead of showing the raw difference."`
+}
+
+func (args DiffArgs) GetImageURLs() []string {
+	return []string{args.ImageURL, args.OtherImageURL}
+}
+
+// Diff shows the difference between two images, with the second stretched to the size of the first.
+func Diff(_ context.Context, inputs []*imagick.MagickWand, args DiffArgs) ([]*imagick.MagickWand, error) {
+	base := inputs[0]
+	other, err := resizeToMatch(inputs[1], base.GetImageWidth(), base.GetImageHeight())
+	if err != nil {
+		return nil, err
+	}
+	defer other.Destroy()
+
+	if args.Highlight {
+		highlighted, _ := base.CompareImages(other, imagick.METRIC_ABSOLUTE_ERROR)
+		if !highlighted.IsVerified() {
+			return nil, fmt.Errorf("error comparing images: %w", base.GetLastError())
+		}
+		return []*imagick.MagickWand{highlighted}, nil
+	}
+
+	err = base.CompositeImage(other, imagick.COMPOSITE_OP_DIFFERENCE, true, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error compositing image: %w", err)
+	}
+
+	return []*imagick.MagickWand{base}, nil
+}
@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
 
@@ -11,7 +12,7 @@ import (
 var divineOverlayImage []byte
 
 type DivineArgs struct {
-	ImageURL   string  `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL   string  `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 	EdgeRadius float64 `default:"5" description:"Edge radius for edge detection."`
 	BlurRadius float64 `default:"4" description:"Gaussian blur radius."`
 	BlurSigma  float64 `default:"2" description:"Sigma value for gaussian blur."`
@@ -24,7 +25,7 @@ func (args DivineArgs) GetImageURL() string {
 	return args.ImageURL
 }
 
-func Divine(wand *imagick.MagickWand, args DivineArgs) ([]*imagick.MagickWand, error) {
+func Divine(_ context.Context, wand *imagick.MagickWand, args DivineArgs) ([]*imagick.MagickWand, error) {
 	overlay := imagick.NewMagickWand()
 	err := overlay.ReadImageBlob(divineOverlayImage)
 	if err != nil {
@@ -0,0 +1,195 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	configPkg "github.com/fogo-sh/borik/pkg/config"
+)
+
+// blockedRanges are the special-purpose ranges that aren't covered by the checks on net.IP, such as
+// net.IP.IsPrivate.
+var blockedRanges = []*net.IPNet{
+	// "This network", which some systems route to the local host.
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	// Shared address space, used for carrier-grade NAT.
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+	// Benchmarking.
+	{IP: net.IPv4(198, 18, 0, 0), Mask: net.CIDRMask(15, 32)},
+	// Reserved, including the limited broadcast address.
+	{IP: net.IPv4(240, 0, 0, 0), Mask: net.CIDRMask(4, 32)},
+	// NAT64, which translates to an arbitrary IPv4 address.
+	{IP: net.ParseIP("64:ff9b::"), Mask: net.CIDRMask(96, 128)},
+}
+
+var errBlockedDestination = newUserError("I'm not allowed to download from that address.")
+
+// Downloader fetches remote media, enforcing timeouts, redirect limits, and restrictions on destination addresses.
+type Downloader struct {
+	client         *http.Client
+	userAgent      string
+	allowedDomains []string
+	deniedDomains  []string
+}
+
+// NewDownloader constructs a new Downloader based on the provided config.
+func NewDownloader(config *configPkg.Config) *Downloader {
+	downloader := &Downloader{
+		userAgent:      config.DownloadUserAgent,
+		allowedDomains: normaliseDomains(config.DownloadAllowedDomains),
+		deniedDomains:  normaliseDomains(config.DownloadDeniedDomains),
+	}
+
+	dialer := &net.Dialer{
+		Timeout: config.DownloadConnectTimeout,
+		// Control is invoked after DNS resolution, so this check cannot be bypassed by a hostname resolving to
+		// a private address.
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return fmt.Errorf("error parsing address: %w", err)
+			}
+			if isBlockedIP(net.ParseIP(host)) {
+				return errBlockedDestination
+			}
+			return nil
+		},
+	}
+
+	downloader.client = &http.Client{
+		Timeout: config.DownloadReadTimeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   config.DownloadConnectTimeout,
+			ResponseHeaderTimeout: config.DownloadReadTimeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > config.DownloadMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", config.DownloadMaxRedirects)
+			}
+			return downloader.checkURL(req.URL)
+		},
+	}
+
+	return downloader
+}
+
+func normaliseDomains(domains []string) []string {
+	var normalised []string
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.Trim(strings.TrimSpace(domain), "."))
+		if domain != "" {
+			normalised = append(normalised, domain)
+		}
+	}
+	return normalised
+}
+
+// isBlockedIP reports whether an address is one that should never be fetched from, such as loopback,
+// private, or link-local addresses. IPv6 addresses embedding an IPv4 address are checked as that address.
+func isBlockedIP(ip net.IP) bool {
+	if ip == nil {
+		return true
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		ip = ipv4
+	} else if ipv4Compatible(ip) {
+		ip = ip[12:]
+	}
+
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() ||
+		slices.ContainsFunc(blockedRanges, func(network *net.IPNet) bool { return network.Contains(ip) })
+}
+
+// ipv4Compatible reports whether an address is a deprecated IPv4-compatible IPv6 address, such as ::127.0.0.1.
+func ipv4Compatible(ip net.IP) bool {
+	return len(ip) == net.IPv6len && slices.Equal(ip[:12], make(net.IP, 12))
+}
+
+func domainMatches(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkURL ensures a URL is permitted by the scheme, address, and domain restrictions.
+func (d *Downloader) checkURL(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return newUserError("I can only download from http and https URLs.")
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(target.Hostname(), "."))
+	if host == "" {
+		return newUserError("That URL doesn't have a host.")
+	}
+
+	if ip := net.ParseIP(host); ip != nil && isBlockedIP(ip) {
+		return errBlockedDestination
+	}
+
+	if domainMatches(host, d.deniedDomains) {
+		return errBlockedDestination
+	}
+	if len(d.allowedDomains) > 0 && !domainMatches(host, d.allowedDomains) {
+		return errBlockedDestination
+	}
+
+	return nil
+}
+
+// Get requests a URL, returning the response if it was successful.
+// The caller is responsible for closing the response body.
+func (d *Downloader) Get(ctx context.Context, rawURL string) (*http.Response, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing URL: %w", err)
+	}
+	if err := d.checkURL(target); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", d.userAgent)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		var userErr *UserError
+		if errors.As(err, &userErr) {
+			return nil, userErr
+		}
+		return nil, fmt.Errorf("error requesting URL: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		closeBody(resp.Body, "Error closing unsuccessful response body")
+		log.Debug().Str("url", rawURL).Int("status", resp.StatusCode).Msg("Download returned unsuccessful status")
+		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	return resp, nil
+}
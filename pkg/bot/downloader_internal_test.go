@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsBlockedIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "1.1.1.1", want: false},
+		{ip: "2606:4700:4700::1111", want: false},
+		{ip: "127.0.0.1", want: true},
+		{ip: "10.1.2.3", want: true},
+		{ip: "169.254.169.254", want: true},
+		{ip: "100.64.0.1", want: true},
+		{ip: "0.0.0.0", want: true},
+		{ip: "0.1.2.3", want: true},
+		{ip: "198.18.0.1", want: true},
+		{ip: "198.19.255.255", want: true},
+		{ip: "198.20.0.1", want: false},
+		{ip: "240.0.0.1", want: true},
+		{ip: "255.255.255.255", want: true},
+		{ip: "::1", want: true},
+		{ip: "fe80::1", want: true},
+		{ip: "fd00::1", want: true},
+		{ip: "::ffff:127.0.0.1", want: true},
+		{ip: "::ffff:1.1.1.1", want: false},
+		{ip: "::127.0.0.1", want: true},
+		{ip: "::10.0.0.1", want: true},
+		{ip: "64:ff9b::7f00:1", want: true},
+		{ip: "64:ff9b::101:101", want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			if got := isBlockedIP(net.ParseIP(test.ip)); got != test.want {
+				t.Errorf("isBlockedIP(%s) = %v, want %v", test.ip, got, test.want)
+			}
+		})
+	}
+
+	if !isBlockedIP(nil) {
+		t.Error("isBlockedIP(nil) = false, want true")
+	}
+}
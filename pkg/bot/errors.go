@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// genericErrorMessage is shown to the user when an error has no user-facing message of its own.
+const genericErrorMessage = "Something went wrong while running that command."
+
+// UserError is an error carrying a message that is safe to show to the user who triggered it.
+// Internal details are kept in the wrapped error, which is only ever logged.
+type UserError struct {
+	Message string
+	Err     error
+}
+
+func (e *UserError) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Message, e.Err)
+}
+
+func (e *UserError) Unwrap() error {
+	return e.Err
+}
+
+// newUserError creates a UserError with a formatted message and no underlying cause.
+func newUserError(format string, args ...any) error {
+	return &UserError{Message: fmt.Sprintf(format, args...)}
+}
+
+// withUserMessage attaches a user-facing message to err, unless it already carries one.
+func withUserMessage(err error, message string) error {
+	var userErr *UserError
+	if errors.As(err, &userErr) {
+		return err
+	}
+	return &UserError{Message: message, Err: err}
+}
+
+// userMessage returns the message that should be shown to the user for err.
+func userMessage(err error) string {
+	var userErr *UserError
+	if errors.As(err, &userErr) {
+		return userErr.Message
+	}
+	return genericErrorMessage
+}
+
+// ReportError logs an error and lets the requester know what went wrong.
+// If the operation was cancelled or timed out, that is reported instead of the error itself.
+func (ctx *OperationContext) ReportError(err error) {
+	log.Error().Err(err).Str("command", ctx.GetCommandName()).Msg("Command failed")
+
+	if cause := context.Cause(ctx.Context()); cause != nil {
+		err = cause
+	}
+
+	if sendErr := ctx.SendError(userMessage(err)); sendErr != nil {
+		log.Error().Err(sendErr).Msg("Failed to send error message")
+	}
+}
+
+// SendError sends an error message, visible only to the requester for slash commands.
+func (ctx *OperationContext) SendError(content string) error {
+	if ctx.Message != nil {
+		_, err := ctx.Session.ChannelMessageSendReply(ctx.Message.ChannelID, content, ctx.Message.Reference())
+		return err
+	}
+	if ctx.deferred {
+		// A deferred response cannot be made ephemeral after the fact, so replace it with an ephemeral followup,
+		// unless it already holds a result.
+		if !ctx.responded.Load() {
+			if err := ctx.Session.InteractionResponseDelete(ctx.Interaction.Interaction); err != nil {
+				return fmt.Errorf("error deleting deferred response: %w", err)
+			}
+		}
+		_, err := ctx.Session.FollowupMessageCreate(ctx.Interaction.Interaction, true, &discordgo.WebhookParams{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		})
+		return err
+	}
+	return ctx.Session.InteractionRespond(ctx.Interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
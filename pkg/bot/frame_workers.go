@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// SequentialOperationArgs is implemented by the arguments of operations whose frames must be processed one at a time,
+// such as those which call out to a backend that cannot handle concurrent requests.
+type SequentialOperationArgs interface {
+	ProcessFramesSequentially() bool
+}
+
+// frameSlots limits how many frames are processed at once across every job, so that running several jobs in
+// parallel can't use more CPUs than the bot has been given.
+var frameSlots = sync.OnceValue(func() chan struct{} {
+	return make(chan struct{}, frameSlotCount())
+})
+
+// frameSlotCount returns how many frames may be processed at once across every job.
+func frameSlotCount() int {
+	if Instance.config.FrameWorkers > 0 {
+		return Instance.config.FrameWorkers
+	}
+	return runtime.NumCPU()
+}
+
+// acquireFrameSlot waits until a frame may be processed, or until ctx is done.
+// The returned function must be called once the frame has been processed.
+func acquireFrameSlot(ctx context.Context) (func(), error) {
+	slots := frameSlots()
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
+
+// frameWorkerCount returns how many frames of an operation may be processed at once.
+func frameWorkerCount(args any) int {
+	if sequential, ok := args.(SequentialOperationArgs); ok && sequential.ProcessFramesSequentially() {
+		return 1
+	}
+	return frameSlotCount()
+}
+
+// frameFunc processes a single frame, identified by its index in the input image.
+type frameFunc func(ctx context.Context, index int, frame *imagick.MagickWand) ([]*imagick.MagickWand, error)
+
+// processFrames runs fn on each frame using up to workers goroutines, returning the output for each frame in order.
+// Each frame also waits for one of the slots shared by every job.
+// Processing stops at the first error, or when ctx is done.
+func processFrames(
+	ctx context.Context,
+	frames []*imagick.MagickWand,
+	workers int,
+	fn frameFunc,
+) ([][]*imagick.MagickWand, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	workers = max(1, min(workers, len(frames)))
+	log.Debug().Int("frames", len(frames)).Int("workers", workers).Msg("Processing frames")
+
+	results := make([][]*imagick.MagickWand, len(frames))
+	indices := make(chan int)
+	var completed atomic.Int64
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for index := range indices {
+				log.Debug().Int("frame", index).Msg("Beginning processing frame")
+				output, err := runUntilDone(ctx, func() ([]*imagick.MagickWand, error) {
+					releaseSlot, err := acquireFrameSlot(ctx)
+					if err != nil {
+						return nil, err
+					}
+					defer releaseSlot()
+					return fn(ctx, index, frames[index])
+				})
+				if err != nil {
+					cancel(fmt.Errorf("error processing frame %d: %w", index, err))
+					return
+				}
+				results[index] = output
+
+				if len(frames) > 1 {
+					var preview *imagick.MagickWand
+					if len(output) > 0 {
+						preview = output[0]
+					}
+					ReportProgress(ctx, fmt.Sprintf("Processing frame %d/%d", completed.Add(1), len(frames)), preview)
+				}
+			}
+		}()
+	}
+
+feed:
+	for index := range frames {
+		select {
+		case indices <- index:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
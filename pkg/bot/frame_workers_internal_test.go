@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+
+	configPkg "github.com/fogo-sh/borik/pkg/config"
+)
+
+// peakTracker records the most calls to a frameFunc that have run at once.
+type peakTracker struct {
+	running, peak atomic.Int64
+}
+
+// wrap returns a frameFunc which runs fn, recording how many calls are running at once.
+func (tracker *peakTracker) wrap(fn frameFunc) frameFunc {
+	return func(ctx context.Context, index int, frame *imagick.MagickWand) ([]*imagick.MagickWand, error) {
+		current := tracker.running.Add(1)
+		for {
+			highest := tracker.peak.Load()
+			if current <= highest || tracker.peak.CompareAndSwap(highest, current) {
+				break
+			}
+		}
+		defer tracker.running.Add(-1)
+		return fn(ctx, index, frame)
+	}
+}
+
+// sleepFrame is a frameFunc which takes a little while to process each frame.
+func sleepFrame(_ context.Context, _ int, _ *imagick.MagickWand) ([]*imagick.MagickWand, error) {
+	time.Sleep(5 * time.Millisecond)
+	return nil, nil
+}
+
+func TestProcessFramesSharesFrameSlotsBetweenJobs(t *testing.T) {
+	previous := Instance
+	Instance = &Bot{config: &configPkg.Config{FrameWorkers: 2}}
+	t.Cleanup(func() { Instance = previous })
+	slots := cap(frameSlots())
+
+	tracker := &peakTracker{}
+	fn := tracker.wrap(sleepFrame)
+
+	// Several jobs each asking for more workers than there are slots.
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := processFrames(context.Background(), make([]*imagick.MagickWand, 8), 8, fn); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := tracker.peak.Load(); got > int64(slots) {
+		t.Errorf("%d frames were processed at once, want at most %d", got, slots)
+	}
+}
+
+func TestProcessFramesKeepsFramesInOrder(t *testing.T) {
+	previous := Instance
+	Instance = &Bot{config: &configPkg.Config{FrameWorkers: 2}}
+	t.Cleanup(func() { Instance = previous })
+
+	const frameCount = 8
+	// Earlier frames take longer, so frames finish in roughly the reverse of their order. The length of each
+	// frame's output identifies which frame it came from.
+	fn := func(_ context.Context, index int, _ *imagick.MagickWand) ([]*imagick.MagickWand, error) {
+		time.Sleep(time.Duration(frameCount-index) * 2 * time.Millisecond)
+		return make([]*imagick.MagickWand, index+1), nil
+	}
+
+	results, err := processFrames(context.Background(), make([]*imagick.MagickWand, frameCount), frameCount, fn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(results) != frameCount {
+		t.Fatalf("got %d results, want %d", len(results), frameCount)
+	}
+	for index, output := range results {
+		if len(output) != index+1 {
+			t.Errorf("result %d came from frame %d", index, len(output)-1)
+		}
+	}
+}
+
+func TestProcessFramesSequentialArgsUseOneWorker(t *testing.T) {
+	previous := Instance
+	Instance = &Bot{config: &configPkg.Config{FrameWorkers: 4}}
+	t.Cleanup(func() { Instance = previous })
+
+	workers := frameWorkerCount(ImageEditArgs{})
+	if workers != 1 {
+		t.Errorf("frameWorkerCount(ImageEditArgs{}) = %d, want 1", workers)
+	}
+
+	tracker := &peakTracker{}
+	_, err := processFrames(context.Background(), make([]*imagick.MagickWand, 8), workers, tracker.wrap(sleepFrame))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := tracker.peak.Load(); got != 1 {
+		t.Errorf("%d frames were processed at once, want 1", got)
+	}
+}
@@ -15,8 +15,10 @@ func makeFrameCommand(name, description string, op ImageOperation[FrameArgs]) Co
 	return Command{
 		name:         name,
 		description:  description,
+		category:     frameCategory,
 		textHandler:  MakeImageOpTextCommand(op),
 		slashHandler: MakeImageOpSlashCommand(op),
+		operation:    makeRegisteredOperation(op),
 	}
 }
 
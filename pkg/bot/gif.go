@@ -2,6 +2,8 @@ package bot
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
@@ -14,7 +16,7 @@ import (
 )
 
 type GifArgs struct {
-	VideoURL string  `default:"" description:"URL to the video to process. Leave blank to automatically attempt to find a video."`
+	VideoURL string  `default:"" cache:"-" description:"URL to the video to process. Leave blank to automatically attempt to find a video."`
 	FPS      uint    `default:"10" description:"Frames per second for the GIF."`
 	Width    uint    `default:"320" description:"Width of the GIF in pixels. Height is scaled to preserve aspect ratio."`
 	Duration float64 `default:"10" description:"Maximum video duration to convert, in seconds. Set to 0 to convert the whole video."`
@@ -39,27 +41,36 @@ func PrepareAndInvokeGif(ctx *OperationContext, args GifArgs) {
 		return
 	}
 
-	videoURL := args.VideoURL
-	if videoURL == "" {
-		var err error
-		videoURL, err = ctx.findMediaURL(videoMediaType)
-		if err != nil {
-			log.Error().Err(err).Msg("Error while attempting to find video to process")
-			return
-		}
+	finish, err := ctx.StartJob()
+	if err != nil {
+		ctx.ReportError(err)
+		return
 	}
+	defer finish()
 
-	srcBytes, err := DownloadImage(videoURL)
+	if err := invokeGif(ctx, args); err != nil {
+		ctx.ReportError(err)
+	}
+}
+
+func invokeGif(ctx *OperationContext, args GifArgs) error {
+	videoURL, err := ctx.resolveMediaURL(args.VideoURL, videoMediaType)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to download video to process")
-		return
+		return withUserMessage(
+			err,
+			"I couldn't find a video to convert. Attach one, reply to a message with one, or provide a URL.",
+		)
+	}
+
+	srcBytes, err := DownloadImage(ctx.Context(), videoURL)
+	if err != nil {
+		return withUserMessage(err, "I couldn't download that video.")
 	}
 
 	parsedURL, _ := url.Parse(videoURL)
 	inputFile, err := os.CreateTemp("", "borik-gif-input-*"+path.Ext(parsedURL.Path))
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to create temporary video file")
-		return
+		return fmt.Errorf("error creating temporary video file: %w", err)
 	}
 	inputPath := inputFile.Name()
 	defer func() {
@@ -69,24 +80,20 @@ func PrepareAndInvokeGif(ctx *OperationContext, args GifArgs) {
 	}()
 
 	if _, err := inputFile.Write(srcBytes); err != nil {
-		log.Error().Err(err).Msg("Failed to write temporary video file")
 		_ = inputFile.Close()
-		return
+		return fmt.Errorf("error writing temporary video file: %w", err)
 	}
 	if err := inputFile.Close(); err != nil {
-		log.Error().Err(err).Msg("Failed to close temporary video file")
-		return
+		return fmt.Errorf("error closing temporary video file: %w", err)
 	}
 
 	outputFile, err := os.CreateTemp("", "borik-gif-output-*.gif")
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to create temporary GIF file")
-		return
+		return fmt.Errorf("error creating temporary GIF file: %w", err)
 	}
 	outputPath := outputFile.Name()
 	if err := outputFile.Close(); err != nil {
-		log.Error().Err(err).Msg("Failed to close temporary GIF file")
-		return
+		return fmt.Errorf("error closing temporary GIF file: %w", err)
 	}
 	defer func() {
 		if err := os.Remove(outputPath); err != nil {
@@ -94,18 +101,22 @@ func PrepareAndInvokeGif(ctx *OperationContext, args GifArgs) {
 		}
 	}()
 
-	if err := convertVideoToGIF(inputPath, outputPath, args); err != nil {
-		log.Error().Err(err).Msg("Failed to convert video to GIF")
-		if sendErr := ctx.SendText(fmt.Sprintf("Failed to convert video to GIF: `%s`", err.Error())); sendErr != nil {
-			log.Error().Err(sendErr).Msg("Failed to send error message")
-		}
-		return
+	if err := convertVideoToGIF(ctx.Context(), inputPath, outputPath, args); err != nil {
+		return withUserMessage(err, "I couldn't convert that video. It may be corrupt, or in a format I don't support.")
 	}
 
 	gifBytes, err := os.ReadFile(outputPath)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to read output GIF")
-		return
+		return fmt.Errorf("error reading output GIF: %w", err)
+	}
+
+	uploadLimit := ctx.GetUploadLimit()
+	gifBytes, reductions, err := fitBlobToUploadLimit(gifBytes, uploadLimit)
+	if errors.Is(err, errResultTooLarge) {
+		return &UserError{Message: uploadLimitError(uploadLimit), Err: err}
+	}
+	if err != nil {
+		return fmt.Errorf("error reducing GIF size: %w", err)
 	}
 
 	originalFileName := path.Base(parsedURL.Path)
@@ -116,7 +127,7 @@ func PrepareAndInvokeGif(ctx *OperationContext, args GifArgs) {
 	resultFileName := fmt.Sprintf("%s.gif", originalFileNameNoExt)
 
 	log.Debug().Msg("GIF processed, uploading result")
-	err = ctx.SendFiles([]*discordgo.File{
+	err = ctx.SendFilesWithContent(uploadLimitMessage(uploadLimit, reductions), []*discordgo.File{
 		{
 			Name:        resultFileName,
 			ContentType: "image/gif",
@@ -124,19 +135,18 @@ func PrepareAndInvokeGif(ctx *OperationContext, args GifArgs) {
 		},
 	})
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to send GIF")
-		if sendErr := ctx.SendText(fmt.Sprintf("Failed to send resulting GIF: `%s`", err.Error())); sendErr != nil {
-			log.Error().Err(sendErr).Msg("Failed to send error message")
-		}
+		return withUserMessage(fmt.Errorf("error sending GIF: %w", err), "I couldn't upload the result.")
 	}
+
+	return nil
 }
 
-func convertVideoToGIF(inputPath string, outputPath string, args GifArgs) error {
+func convertVideoToGIF(ctx context.Context, inputPath string, outputPath string, args GifArgs) error {
 	if args.FPS == 0 {
-		return fmt.Errorf("fps must be greater than 0")
+		return newUserError("FPS must be greater than 0.")
 	}
 	if args.Width == 0 {
-		return fmt.Errorf("width must be greater than 0")
+		return newUserError("Width must be greater than 0.")
 	}
 
 	ffmpegArgs := []string{
@@ -160,7 +170,7 @@ func convertVideoToGIF(inputPath string, outputPath string, args GifArgs) error
 		outputPath,
 	)
 
-	output, err := exec.Command("ffmpeg", ffmpegArgs...).CombinedOutput()
+	output, err := exec.CommandContext(ctx, "ffmpeg", ffmpegArgs...).CombinedOutput()
 	if err != nil {
 		message := strings.TrimSpace(string(output))
 		if message == "" {
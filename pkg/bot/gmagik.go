@@ -1,14 +1,15 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
 
 type GmagikArgs struct {
-	ImageURL         string  `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
-	Scale            float64 `default:"1" description:"Scale of the magikification. Larger numbers produce more destroyed images."`
+	ImageURL         string  `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
+	Scale            float64 `default:"1" primary:"true" description:"Scale of the magikification. Larger numbers produce more destroyed images."`
 	Iterations       uint    `default:"5" description:"Number of iterations of magikification to run."`
 	WidthMultiplier  float64 `default:"0.5" description:"Multiplier to apply to the width of the input image to produce the intermediary image."`
 	HeightMultiplier float64 `default:"0.5" description:"Multiplier to apply to the height of the input image to produce the intermediary image."`
@@ -19,13 +20,18 @@ func (args GmagikArgs) GetImageURL() string {
 }
 
 // Gmagik runs content-aware scaling on an image.
-func Gmagik(wand *imagick.MagickWand, args GmagikArgs) ([]*imagick.MagickWand, error) {
+func Gmagik(ctx context.Context, wand *imagick.MagickWand, args GmagikArgs) ([]*imagick.MagickWand, error) {
 	var results []*imagick.MagickWand
 
 	lastFrame := wand
 
 	for i := uint(0); i < args.Iterations; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		newFrame, err := Magik(
+			ctx,
 			lastFrame.Clone(),
 			MagikArgs{
 				Scale:            args.Scale,
@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -105,7 +106,7 @@ func convertGraphicsFormat(
 }
 
 type graphicsFormatArgs struct {
-	ImageURL string `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL string `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 	Dither   bool   `default:"false" description:"Whether the final image should be dithered."`
 }
 
@@ -114,7 +115,7 @@ func (args graphicsFormatArgs) GetImageURL() string {
 }
 
 func makeGraphicsFormatOp(format graphicsFormat) ImageOperation[graphicsFormatArgs] {
-	return func(wand *imagick.MagickWand, args graphicsFormatArgs) ([]*imagick.MagickWand, error) {
+	return func(_ context.Context, wand *imagick.MagickWand, args graphicsFormatArgs) ([]*imagick.MagickWand, error) {
 		return convertGraphicsFormat(wand, format, args.Dither)
 	}
 }
@@ -126,8 +127,10 @@ func generateGraphicsFormatCommands() []Command {
 		cmds = append(cmds, Command{
 			name:         strings.ToLower(format.Name),
 			description:  fmt.Sprintf("Convert an image to %s graphics", format.Name),
+			category:     retroCategory,
 			textHandler:  MakeImageOpTextCommand(op),
 			slashHandler: MakeImageOpSlashCommand(op),
+			operation:    makeRegisteredOperation(op),
 		})
 	}
 	return cmds
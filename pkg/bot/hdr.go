@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
 
@@ -11,8 +12,8 @@ import (
 var icc2020Profile []byte
 
 type HdrArgs struct {
-	ImageURL      string  `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
-	Multiply      float64 `default:"1.5" description:"Multiplier for pixel values. Higher values produce brighter, more saturated results."`
+	ImageURL      string  `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
+	Multiply      float64 `default:"1.5" primary:"true" description:"Multiplier for pixel values. Higher values produce brighter, more saturated results."`
 	GammaExponent float64 `default:"0.9" description:"Exponent for gamma power curve. Lower values brighten midtones more."`
 }
 
@@ -23,7 +24,7 @@ func (args HdrArgs) GetImageURL() string {
 // Hdr applies aggressive color boosting to an image, producing an over-processed HDR look.
 // Converts to linear RGB, auto-adjusts gamma, multiplies and power-curves pixel values,
 // converts back to sRGB, and applies a Display P3 ICC profile.
-func Hdr(wand *imagick.MagickWand, args HdrArgs) ([]*imagick.MagickWand, error) {
+func Hdr(_ context.Context, wand *imagick.MagickWand, args HdrArgs) ([]*imagick.MagickWand, error) {
 	// Convert to linear RGB colorspace
 	err := wand.TransformImageColorspace(imagick.COLORSPACE_RGB)
 	if err != nil {
@@ -2,31 +2,144 @@ package bot
 
 import (
 	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/nint8835/parsley"
 	"github.com/rs/zerolog/log"
 )
 
 type HelpArgs struct {
-	Command string `default:"" description:"Command to get help for."`
+	Command string `default:"" autocomplete:"commands" description:"Command to get help for."`
 }
 
-func generateCommandList() string {
-	var commandCodeBlock strings.Builder
-	commandCodeBlock.WriteString("```")
+// helpPrefix prefixes the custom ID of the buttons for paging through the command list.
+const helpPrefix = "help"
 
-	for _, details := range Instance.textParser.GetCommands() {
-		_, _ = fmt.Fprintf(
-			&commandCodeBlock,
-			"%s%s: %s\n",
-			Instance.config.Prefixes[0],
-			details.Name,
-			details.Description,
-		)
+// helpColour is the colour of help embeds.
+const helpColour = (206 << 16) + (147 << 8) + 216
+
+// helpPage is a page of the command list, showing the commands in a category.
+type helpPage struct {
+	category *commandCategory
+	commands []*Command
+}
+
+// otherCategory holds any commands without a category of their own in help.
+var otherCategory = &commandCategory{name: "other", title: "Other", description: "Everything else."}
+
+// generateHelpPages groups the enabled commands into a page for each category that has any.
+func generateHelpPages() []helpPage {
+	byCategory := map[*commandCategory][]*Command{}
+	for _, name := range slices.Sorted(maps.Keys(enabledCommands)) {
+		command := enabledCommands[name]
+		category := command.category
+		if category == nil {
+			category = otherCategory
+		}
+		byCategory[category] = append(byCategory[category], command)
+	}
+
+	var pages []helpPage
+	for _, category := range append(slices.Clone(commandCategories), otherCategory) {
+		if commands := byCategory[category]; len(commands) > 0 {
+			pages = append(pages, helpPage{category: category, commands: commands})
+		}
+	}
+	return pages
+}
+
+// helpPageCustomID returns the custom ID of a button going to the given page of the command list.
+func helpPageCustomID(page int) string {
+	return strings.Join([]string{helpPrefix, strconv.Itoa(page)}, ":")
+}
+
+// generateCommandList renders a page of the command list, along with the buttons for moving between pages.
+func generateCommandList(page int) (*discordgo.MessageEmbed, []discordgo.MessageComponent) {
+	pages := generateHelpPages()
+	page = max(0, min(page, len(pages)-1))
+	prefix := Instance.config.Prefixes[0]
+
+	embed := &discordgo.MessageEmbed{
+		Title: "Commands",
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d of %d. Use %shelp <command> for details on a command.", page+1, len(pages), prefix),
+		},
+		Color: helpColour,
+	}
+	if len(pages) > 0 {
+		current := pages[page]
+		lines := []string{current.category.description, ""}
+		for _, command := range current.commands {
+			lines = append(lines, fmt.Sprintf("`%s%s`: %s", prefix, command.name, command.description))
+		}
+		embed.Title = fmt.Sprintf("Commands: %s", current.category.title)
+		embed.Description = strings.Join(lines, "\n")
+	}
+
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Previous",
+					Style:    discordgo.SecondaryButton,
+					CustomID: helpPageCustomID(page - 1),
+					Disabled: page <= 0,
+				},
+				discordgo.Button{
+					Label:    "Next",
+					Style:    discordgo.SecondaryButton,
+					CustomID: helpPageCustomID(page + 1),
+					Disabled: page >= len(pages)-1,
+				},
+			},
+		},
+	}
+	return embed, components
+}
+
+// commandUsage renders the syntax of a text command, marking optional arguments with brackets.
+func commandUsage(command string, arguments []parsley.ArgumentDetails) string {
+	usage := []string{Instance.config.Prefixes[0] + command}
+	for _, argDetails := range arguments {
+		if argDetails.Required {
+			usage = append(usage, fmt.Sprintf("<%s>", argDetails.Name))
+		} else {
+			usage = append(usage, fmt.Sprintf("[%s]", argDetails.Name))
+		}
+	}
+	return fmt.Sprintf("`%s`", strings.Join(usage, " "))
+}
+
+// commandExamples renders the examples of a command as text commands.
+func commandExamples(command *Command) string {
+	examples := make([]string, 0, len(command.examples))
+	for _, example := range command.examples {
+		usage := strings.TrimSpace(fmt.Sprintf("%s%s %s", Instance.config.Prefixes[0], command.name, example))
+		examples = append(examples, fmt.Sprintf("`%s`", usage))
+	}
+	return strings.Join(examples, "\n")
+}
+
+// slashCommandPaths returns the slash commands a command can be run with, such as /magik or /overlay jackpog.
+func slashCommandPaths(command *Command) []string {
+	if command.slashHandler == nil || !Instance.slashEnabled {
+		return nil
 	}
 
-	return commandCodeBlock.String() + "```"
+	var paths []string
+	for _, name := range slices.Concat([]string{command.name}, command.aliases, command.slashAliases) {
+		if category := command.category; category != nil && category.subcommands {
+			paths = append(paths, fmt.Sprintf("`/%s %s`", category.name, strings.TrimPrefix(name, category.prefix)))
+		} else {
+			paths = append(paths, fmt.Sprintf("`/%s`", name))
+		}
+	}
+	return paths
 }
 
 func generateCommandHelp(command string) (*discordgo.MessageEmbed, error) {
@@ -37,10 +150,44 @@ func generateCommandHelp(command string) (*discordgo.MessageEmbed, error) {
 	embed := &discordgo.MessageEmbed{
 		Title:       fmt.Sprintf("%s%s", Instance.config.Prefixes[0], command),
 		Description: commandDetails.Description,
-		Fields:      []*discordgo.MessageEmbedField{},
-		Color:       (206 << 16) + (147 << 8) + 216,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Usage", Value: commandUsage(command, commandDetails.Arguments)},
+		},
+		Color: helpColour,
 	}
 
+	if details, ok := enabledCommands[commandNames[command]]; ok {
+		if len(details.examples) > 0 {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name:  "Examples",
+				Value: commandExamples(details),
+			})
+		}
+
+		var aliases []string
+		for _, alias := range slices.Concat([]string{details.name}, details.aliases) {
+			if alias != command {
+				aliases = append(aliases, fmt.Sprintf("`%s%s`", Instance.config.Prefixes[0], alias))
+			}
+		}
+		if len(aliases) > 0 {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name:   "Aliases",
+				Value:  strings.Join(aliases, ", "),
+				Inline: true,
+			})
+		}
+
+		if paths := slashCommandPaths(details); len(paths) > 0 {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name:   "Slash commands",
+				Value:  strings.Join(paths, ", "),
+				Inline: true,
+			})
+		}
+	}
+
+	argsType := commandArgsTypes[command]
 	for _, argDetails := range commandDetails.Arguments {
 		argDetailsStr := ""
 		if argDetails.Required {
@@ -48,6 +195,9 @@ func generateCommandHelp(command string) (*discordgo.MessageEmbed, error) {
 		} else {
 			argDetailsStr = fmt.Sprintf("%s\nType: %s\nDefault: %s", argDetails.Description, argDetails.Type, argDetails.Default)
 		}
+		if argsType != nil {
+			argDetailsStr += formatArgChoices(argsType, argDetails.Name)
+		}
 		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
 			Name:  argDetails.Name,
 			Value: argDetailsStr,
@@ -56,13 +206,29 @@ func generateCommandHelp(command string) (*discordgo.MessageEmbed, error) {
 	return embed, nil
 }
 
+// formatArgChoices lists the values an argument is limited to, if any, for its help text.
+func formatArgChoices(argsType reflect.Type, name string) string {
+	field, ok := argsType.FieldByName(name)
+	if !ok {
+		return ""
+	}
+
+	choices := argChoices(field)
+	switch {
+	case len(choices) > maxOptionChoices:
+		return fmt.Sprintf("\nChoices: %s, …", strings.Join(choices[:maxOptionChoices], ", "))
+	case len(choices) > 0:
+		return fmt.Sprintf("\nChoices: %s", strings.Join(choices, ", "))
+	default:
+		return ""
+	}
+}
+
 func help(ctx *OperationContext, args HelpArgs) {
 	if args.Command != "" {
-		embed, err := generateCommandHelp(args.Command)
+		embed, err := generateCommandHelp(strings.ToLower(strings.TrimPrefix(args.Command, Instance.config.Prefixes[0])))
 		if err != nil {
-			if sendErr := ctx.SendText(fmt.Sprintf("```\n%s\n```", err.Error())); sendErr != nil {
-				log.Error().Err(sendErr).Msg("Error sending error message")
-			}
+			ctx.ReportError(&UserError{Message: fmt.Sprintf("I don't have a command called `%s`.", args.Command), Err: err})
 			return
 		}
 
@@ -70,7 +236,8 @@ func help(ctx *OperationContext, args HelpArgs) {
 			log.Error().Err(err).Msg("Failed to send help message")
 		}
 	} else {
-		if err := ctx.SendText(generateCommandList()); err != nil {
+		embed, components := generateCommandList(0)
+		if err := ctx.sendEmbedWithComponents(embed, components); err != nil {
 			log.Error().Err(err).Msg("Failed to send help message")
 		}
 	}
@@ -83,3 +250,28 @@ func HelpCommand(message *discordgo.MessageCreate, args HelpArgs) {
 func HelpSlashCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate, args HelpArgs) {
 	help(NewOperationContextFromInteraction(session, interaction), args)
 }
+
+// handleHelpComponent handles the buttons for paging through the command list.
+func handleHelpComponent(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	pageID, ok := strings.CutPrefix(interaction.MessageComponentData().CustomID, helpPrefix+":")
+	if !ok {
+		return
+	}
+	page, err := strconv.Atoi(pageID)
+	if err != nil {
+		log.Warn().Str("custom_id", interaction.MessageComponentData().CustomID).Msg("Invalid help page")
+		return
+	}
+
+	embed, components := generateCommandList(page)
+	err = session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to update help message")
+	}
+}
@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"bytes"
+	"net/url"
+	"path"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// lastResultShortcut can be given in place of an image URL to refer to the last result posted in a channel.
+const lastResultShortcut = "^"
+
+// channelHistory tracks the images that results in a single channel were produced from and produced,
+// most recent last.
+type channelHistory struct {
+	versions            []string
+	lastResultMessageID string
+}
+
+// channelHistoryStore remembers the history of results in each channel.
+type channelHistoryStore struct {
+	mu       sync.Mutex
+	channels map[string]*channelHistory
+}
+
+var channelHistories = &channelHistoryStore{channels: map[string]*channelHistory{}}
+
+// record adds a result to the history of a channel.
+func (s *channelHistoryStore) record(channelID string, inputURL string, outputURL string, messageID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history, ok := s.channels[channelID]
+	if !ok {
+		history = &channelHistory{}
+		s.channels[channelID] = history
+	}
+
+	if len(history.versions) == 0 || history.versions[len(history.versions)-1] != inputURL {
+		history.versions = append(history.versions, inputURL)
+	}
+	history.versions = append(history.versions, outputURL)
+	history.lastResultMessageID = messageID
+
+	if size := Instance.config.ChannelHistorySize; size > 0 && len(history.versions) > size {
+		history.versions = history.versions[len(history.versions)-size:]
+	}
+}
+
+// current returns the most recent image in a channel's history.
+func (s *channelHistoryStore) current(channelID string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history, ok := s.channels[channelID]
+	if !ok || len(history.versions) == 0 {
+		return "", false
+	}
+	return history.versions[len(history.versions)-1], true
+}
+
+// currentIfLatest returns the most recent image in a channel's history,
+// but only if the given message is the result that produced it.
+func (s *channelHistoryStore) currentIfLatest(channelID string, messageID string) (string, bool) {
+	s.mu.Lock()
+	history, ok := s.channels[channelID]
+	latest := ok && history.lastResultMessageID == messageID
+	s.mu.Unlock()
+
+	if !latest {
+		return "", false
+	}
+	return s.current(channelID)
+}
+
+// undo drops the most recent image from a channel's history, returning the one before it.
+func (s *channelHistoryStore) undo(channelID string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history, ok := s.channels[channelID]
+	if !ok || len(history.versions) < 2 {
+		return "", false
+	}
+	history.versions = history.versions[:len(history.versions)-1]
+	history.lastResultMessageID = ""
+	return history.versions[len(history.versions)-1], true
+}
+
+// setLatestMessage marks a message as showing the most recent image in a channel's history.
+func (s *channelHistoryStore) setLatestMessage(channelID string, messageID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if history, ok := s.channels[channelID]; ok {
+		history.lastResultMessageID = messageID
+	}
+}
+
+// attachmentURL returns the URL of the first file attached to a message.
+func attachmentURL(message *discordgo.Message) string {
+	if message == nil || len(message.Attachments) == 0 {
+		return ""
+	}
+	return message.Attachments[0].URL
+}
+
+// resolveLastResult resolves the last result shortcut to the most recent image in the operation's channel.
+func (ctx *OperationContext) resolveLastResult() (string, error) {
+	imageURL, ok := channelHistories.current(ctx.GetChannelID())
+	if !ok {
+		return "", newUserError("I haven't posted any results in this channel yet, so there's nothing for ^ to refer to.")
+	}
+	return imageURL, nil
+}
+
+type UndoArgs struct{}
+
+// undoLastResult goes back to the image the last result in a channel was produced from, posting it again so that it
+// can be picked up by the next command.
+func undoLastResult(ctx *OperationContext) {
+	defer TypingIndicatorForContext(ctx)()
+
+	if err := ctx.DeferResponse(); err != nil {
+		log.Error().Err(err).Msg("Failed to defer response")
+		return
+	}
+
+	imageURL, ok := channelHistories.undo(ctx.GetChannelID())
+	if !ok {
+		ctx.ReportError(newUserError("There's nothing to undo in this channel."))
+		return
+	}
+
+	blob, err := DownloadImage(ctx.Context(), imageURL)
+	if err != nil {
+		ctx.ReportError(withUserMessage(err, "I couldn't download the previous image."))
+		return
+	}
+
+	filename := "undo.png"
+	if parsedURL, err := url.Parse(imageURL); err == nil && path.Base(parsedURL.Path) != "/" {
+		filename = path.Base(parsedURL.Path)
+	}
+
+	message, err := ctx.sendFilesWithComponents(
+		"Went back to the previous image.",
+		[]*discordgo.File{{Name: filename, Reader: bytes.NewReader(blob)}},
+		nil,
+	)
+	if err != nil {
+		ctx.ReportError(withUserMessage(err, "I couldn't upload the previous image."))
+		return
+	}
+	channelHistories.setLatestMessage(ctx.GetChannelID(), message.ID)
+}
+
+// UndoCommand goes back to the image the last result in the channel was produced from.
+func UndoCommand(message *discordgo.MessageCreate, _ UndoArgs) {
+	undoLastResult(NewOperationContextFromMessage(Instance.session, message))
+}
+
+func UndoSlashCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate, _ UndoArgs) {
+	undoLastResult(NewOperationContextFromInteraction(session, interaction))
+}
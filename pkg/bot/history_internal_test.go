@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"testing"
+
+	configPkg "github.com/fogo-sh/borik/pkg/config"
+)
+
+// newTestHistoryStore creates an empty history store that keeps the given number of versions per channel.
+func newTestHistoryStore(t *testing.T, size int) *channelHistoryStore {
+	t.Helper()
+	previous := Instance
+	Instance = &Bot{config: &configPkg.Config{ChannelHistorySize: size}}
+	t.Cleanup(func() { Instance = previous })
+	return &channelHistoryStore{channels: map[string]*channelHistory{}}
+}
+
+// expectCurrent checks the most recent image in a channel's history.
+func expectCurrent(t *testing.T, store *channelHistoryStore, channelID string, want string) {
+	t.Helper()
+	got, ok := store.current(channelID)
+	if !ok || got != want {
+		t.Errorf("current(%q) = (%q, %v), want (%q, true)", channelID, got, ok, want)
+	}
+}
+
+func TestChannelHistoryRecord(t *testing.T) {
+	store := newTestHistoryStore(t, 20)
+
+	if _, ok := store.current("channel"); ok {
+		t.Error("an empty channel has a current image")
+	}
+
+	store.record("channel", "input", "first", "message 1")
+	expectCurrent(t, store, "channel", "first")
+
+	// Working on the last result doesn't record its input a second time.
+	store.record("channel", "first", "second", "message 2")
+	if got := store.channels["channel"].versions; len(got) != 3 {
+		t.Errorf("versions = %v, want [input first second]", got)
+	}
+	expectCurrent(t, store, "channel", "second")
+
+	// Channels are kept apart.
+	if _, ok := store.current("other channel"); ok {
+		t.Error("a result in one channel was visible in another")
+	}
+}
+
+func TestChannelHistoryRecordTrimsToSize(t *testing.T) {
+	store := newTestHistoryStore(t, 3)
+
+	store.record("channel", "a", "b", "message 1")
+	store.record("channel", "b", "c", "message 2")
+	store.record("channel", "c", "d", "message 3")
+
+	versions := store.channels["channel"].versions
+	if len(versions) != 3 || versions[0] != "b" || versions[2] != "d" {
+		t.Errorf("versions = %v, want [b c d]", versions)
+	}
+}
+
+func TestChannelHistoryUndo(t *testing.T) {
+	store := newTestHistoryStore(t, 20)
+
+	if _, ok := store.undo("channel"); ok {
+		t.Error("undo succeeded in an empty channel")
+	}
+
+	store.record("channel", "input", "first", "message 1")
+	store.record("channel", "first", "second", "message 2")
+
+	for _, want := range []string{"first", "input"} {
+		got, ok := store.undo("channel")
+		if !ok || got != want {
+			t.Errorf("undo = (%q, %v), want (%q, true)", got, ok, want)
+		}
+		expectCurrent(t, store, "channel", want)
+	}
+
+	if _, ok := store.undo("channel"); ok {
+		t.Error("undo went past the first image")
+	}
+}
+
+func TestChannelHistoryCurrentIfLatest(t *testing.T) {
+	store := newTestHistoryStore(t, 20)
+
+	store.record("channel", "input", "first", "message 1")
+	store.record("channel", "first", "second", "message 2")
+
+	if got, ok := store.currentIfLatest("channel", "message 2"); !ok || got != "second" {
+		t.Errorf("currentIfLatest for the latest result = (%q, %v), want (%q, true)", got, ok, "second")
+	}
+	if _, ok := store.currentIfLatest("channel", "message 1"); ok {
+		t.Error("currentIfLatest succeeded for an older result")
+	}
+
+	// After an undo no result shows the current image until the undone image is posted again.
+	store.undo("channel")
+	if _, ok := store.currentIfLatest("channel", "message 2"); ok {
+		t.Error("currentIfLatest succeeded for an undone result")
+	}
+	store.setLatestMessage("channel", "message 3")
+	if got, ok := store.currentIfLatest("channel", "message 3"); !ok || got != "first" {
+		t.Errorf("currentIfLatest after undo = (%q, %v), want (%q, true)", got, ok, "first")
+	}
+}
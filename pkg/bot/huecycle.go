@@ -1,13 +1,14 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
 
 type HueCycleArgs struct {
-	ImageURL string `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL string `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 	Steps    uint   `default:"20" description:"Number of steps to do the hue shift in."`
 }
 
@@ -16,10 +17,14 @@ func (args HueCycleArgs) GetImageURL() string {
 }
 
 // HueCycle cycles the hue on an image.
-func HueCycle(wand *imagick.MagickWand, args HueCycleArgs) ([]*imagick.MagickWand, error) {
+func HueCycle(ctx context.Context, wand *imagick.MagickWand, args HueCycleArgs) ([]*imagick.MagickWand, error) {
 	wands := []*imagick.MagickWand{wand}
 
 	for i := uint(0); i < args.Steps; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		wand = wand.Clone()
 		err := wand.ModulateImage(100, 100, 100+(200/float64(args.Steps)))
 		if err != nil {
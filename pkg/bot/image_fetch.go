@@ -2,10 +2,8 @@ package bot
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"path"
 
 	"github.com/bwmarrin/discordgo"
@@ -23,6 +21,19 @@ type AvatarSlashArgs struct {
 	UseGuildAvatar bool            `default:"true" description:"Attempt to fetch the user's guild avatar first. Disable to always use their global avatar."`
 }
 
+// avatarURL returns the URL of a user's avatar, preferring their avatar for the given guild if asked to.
+func avatarURL(ctx *OperationContext, targetUser *discordgo.User, guildID string, useGuildAvatar bool) (string, error) {
+	member, err := ctx.Session.GuildMember(guildID, targetUser.ID)
+	if err != nil {
+		return "", withUserMessage(fmt.Errorf("error fetching member: %w", err), "I couldn't find that user here.")
+	}
+
+	if useGuildAvatar {
+		return member.AvatarURL("1024"), nil
+	}
+	return targetUser.AvatarURL("1024"), nil
+}
+
 func fetchAvatar(ctx *OperationContext, targetUser *discordgo.User, guildID string, useGuildAvatar bool) {
 	defer TypingIndicatorForContext(ctx)()
 
@@ -31,22 +42,15 @@ func fetchAvatar(ctx *OperationContext, targetUser *discordgo.User, guildID stri
 		return
 	}
 
-	member, err := ctx.Session.GuildMember(guildID, targetUser.ID)
+	avatarUrl, err := avatarURL(ctx, targetUser, guildID, useGuildAvatar)
 	if err != nil {
-		log.Error().Err(err).Msg("Error fetching member")
+		ctx.ReportError(err)
 		return
 	}
 
-	var avatarUrl string
-	if useGuildAvatar {
-		avatarUrl = member.AvatarURL("1024")
-	} else {
-		avatarUrl = targetUser.AvatarURL("1024")
-	}
-
-	resp, err := http.Get(avatarUrl)
+	resp, err := Instance.downloader.Get(ctx.Context(), avatarUrl)
 	if err != nil {
-		log.Error().Err(err).Msg("Error downloading avatar")
+		ctx.ReportError(withUserMessage(fmt.Errorf("error downloading avatar: %w", err), "I couldn't download that avatar."))
 		return
 	}
 	defer closeBody(resp.Body, "Error closing avatar response body")
@@ -58,7 +62,7 @@ func fetchAvatar(ctx *OperationContext, targetUser *discordgo.User, guildID stri
 	}
 
 	if err := ctx.SendFiles([]*discordgo.File{file}); err != nil {
-		log.Error().Err(err).Msg("Failed to send avatar")
+		ctx.ReportError(withUserMessage(fmt.Errorf("error sending avatar: %w", err), "I couldn't upload the avatar."))
 	}
 }
 
@@ -97,9 +101,7 @@ func AvatarSlashCommand(session *discordgo.Session, interaction *discordgo.Inter
 	}
 
 	if targetUser == nil {
-		if err := ctx.SendText("Unable to determine target user."); err != nil {
-			log.Error().Err(err).Msg("Failed to send error message")
-		}
+		ctx.ReportError(newUserError("Unable to determine target user."))
 		return
 	}
 
@@ -124,19 +126,20 @@ func getStickerUrl(sticker *discordgo.StickerItem) (string, string, error) {
 			sticker.ID,
 		), "image/gif", nil
 	case discordgo.StickerFormatTypeLottie:
-		return "", "", errors.New("this command does not currently support Lottie / built-in stickers")
+		return "", "", newUserError("I can't fetch Lottie or built-in stickers yet.")
 	default:
-		return "", "", errors.New("unknown sticker format")
+		return "", "", newUserError("I don't know how to fetch stickers in that format.")
 	}
 }
 
-func apngToGif(apngInput io.Reader) (io.Reader, error) {
-	input, err := io.ReadAll(apngInput)
+func apngToGif(input []byte) (io.Reader, error) {
+	err := checkImageLimits(input, "APNG:profile.png")
 	if err != nil {
-		return nil, fmt.Errorf("error copying input: %w", err)
+		return nil, err
 	}
 
 	wand := imagick.NewMagickWand()
+	defer wand.Destroy()
 
 	err = wand.SetFilename("APNG:profile.png")
 	if err != nil {
@@ -148,23 +151,18 @@ func apngToGif(apngInput io.Reader) (io.Reader, error) {
 		return nil, fmt.Errorf("error reading input image: %w", err)
 	}
 
-	for i := uint(0); i < wand.GetNumberImages(); i++ {
-		wand.SetIteratorIndex(int(i))
-		err = wand.SetImageDispose(imagick.DISPOSE_BACKGROUND)
-		if err != nil {
-			return nil, fmt.Errorf("error configuring disposal: %w", err)
-		}
+	coalesced, err := coalesceAPNG(wand)
+	if err != nil {
+		return nil, err
 	}
+	defer coalesced.Destroy()
 
-	wand.ResetIterator()
-	wand.CoalesceImages()
-
-	err = wand.SetFilename("profile.gif")
+	err = coalesced.SetFilename("profile.gif")
 	if err != nil {
 		return nil, fmt.Errorf("error setting format: %w", err)
 	}
 
-	imageBlob, err := wand.GetImagesBlob()
+	imageBlob, err := coalesced.GetImagesBlob()
 	if err != nil {
 		return nil, fmt.Errorf("error generating output image: %w", err)
 	}
@@ -176,23 +174,44 @@ func apngToGif(apngInput io.Reader) (io.Reader, error) {
 	}
 
 	if outBuffer.Len() == 0 {
-		return nil, fmt.Errorf("got an empty output image - your provided sticker may be one of the currently broken ones")
+		return nil, newUserError("Converting that sticker gave an empty image - it may be one I can't currently convert.")
 	}
 
 	return outBuffer, nil
 }
 
+// Sticker fetches the sticker on a message, the message it replies to, or a recent message, converting animated
+// stickers to GIFs.
 func Sticker(message *discordgo.MessageCreate, args struct{}) {
+	ctx := NewOperationContextFromMessage(Instance.session, message)
+	defer TypingIndicatorForContext(ctx)()
+
+	finish, err := ctx.StartJob()
+	if err != nil {
+		ctx.ReportError(err)
+		return
+	}
+	defer finish()
+
+	if err := invokeSticker(ctx); err != nil {
+		ctx.ReportError(err)
+	}
+}
+
+func invokeSticker(ctx *OperationContext) error {
+	message := ctx.Message
 	var targetSticker *discordgo.StickerItem
 	if len(message.StickerItems) >= 1 {
 		targetSticker = message.StickerItems[0]
 	} else if message.ReferencedMessage != nil && len(message.ReferencedMessage.StickerItems) >= 1 {
 		targetSticker = message.ReferencedMessage.StickerItems[0]
 	} else {
-		messages, err := Instance.session.ChannelMessages(message.ChannelID, 20, message.ID, "", "")
+		messages, err := ctx.Session.ChannelMessages(message.ChannelID, 20, message.ID, "", "")
 		if err != nil {
-			log.Error().Err(err).Msg("Error fetching message history")
-			return
+			return withUserMessage(
+				fmt.Errorf("error fetching message history: %w", err),
+				"I couldn't look through earlier messages for a sticker.",
+			)
 		}
 
 		for _, message := range messages {
@@ -204,74 +223,46 @@ func Sticker(message *discordgo.MessageCreate, args struct{}) {
 	}
 
 	if targetSticker == nil {
-		_, err := Instance.session.ChannelMessageSendReply(
-			message.ChannelID,
-			"No sticker found! Please post the sticker you are looking for and try again, "+
+		return newUserError(
+			"No sticker found! Please post the sticker you are looking for and try again, " +
 				"or retry this command as a reply on the target message.",
-			message.Reference(),
 		)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send missing sticker error")
-		}
-		return
 	}
 
 	stickerUrl, contentType, err := getStickerUrl(targetSticker)
 	if err != nil {
-		_, err := Instance.session.ChannelMessageSendReply(
-			message.ChannelID,
-			fmt.Sprintf("Unable to fetch sticker: %s", err),
-			message.Reference(),
-		)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send sticker fetch error")
-		}
-		return
+		return err
 	}
 
-	resp, err := http.Get(stickerUrl)
+	resp, err := Instance.downloader.Get(ctx.Context(), stickerUrl)
 	if err != nil {
-		log.Error().Err(err).Msg("Error downloading sticker")
-		return
+		return withUserMessage(fmt.Errorf("error downloading sticker: %w", err), "I couldn't download that sticker.")
 	}
 	defer closeBody(resp.Body, "Error closing sticker response body")
 
-	var file io.Reader
-	var filename string
+	input, err := readDownload(resp)
+	if err != nil {
+		return withUserMessage(err, "I couldn't download that sticker.")
+	}
+
+	var file io.Reader = bytes.NewReader(input)
+	filename := path.Base(resp.Request.URL.Path)
 	if targetSticker.FormatType == discordgo.StickerFormatTypeAPNG {
-		file, err = apngToGif(resp.Body)
+		file, err = apngToGif(input)
 		if err != nil {
-			_, sendErr := Instance.session.ChannelMessageSendReply(
-				message.ChannelID,
-				fmt.Sprintf("Error converting APNG sticker to GIF:\n```%s```", err),
-				message.Reference(),
+			return withUserMessage(
+				fmt.Errorf("error converting APNG sticker to GIF: %w", err),
+				"I couldn't convert that sticker to a GIF.",
 			)
-			if sendErr != nil {
-				log.Error().Err(sendErr).Msg("Failed to send sticker conversion error")
-			}
-			log.Error().Err(err).Msg("Error converting APNG sticker to GIF")
-			return
 		}
-		filename = path.Base(resp.Request.URL.Path) + ".gif"
-	} else {
-		file = resp.Body
-		filename = path.Base(resp.Request.URL.Path)
-	}
-
-	_, err = Instance.session.ChannelMessageSendComplex(
-		message.ChannelID,
-		&discordgo.MessageSend{
-			Reference: message.Reference(),
-			File: &discordgo.File{
-				Name:        filename,
-				ContentType: contentType,
-				Reader:      file,
-			},
-		},
-	)
+		filename += ".gif"
+	}
+
+	err = ctx.SendFiles([]*discordgo.File{{Name: filename, ContentType: contentType, Reader: file}})
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to send sticker")
+		return withUserMessage(fmt.Errorf("error sending sticker: %w", err), "I couldn't upload the sticker.")
 	}
+	return nil
 }
 
 func getEmojiUrl(emoji *discordgo.Emoji) string {
@@ -286,17 +277,37 @@ type EmojiArgs struct {
 	Emoji string `description:"Emoji to fetch as an image. Leave blank to attempt to auto-locate an emoji." default:""`
 }
 
+// Emoji fetches the custom emoji on a message, the message it replies to, or a recent message.
 func Emoji(message *discordgo.MessageCreate, args EmojiArgs) {
+	ctx := NewOperationContextFromMessage(Instance.session, message)
+	defer TypingIndicatorForContext(ctx)()
+
+	finish, err := ctx.StartJob()
+	if err != nil {
+		ctx.ReportError(err)
+		return
+	}
+	defer finish()
+
+	if err := invokeEmoji(ctx); err != nil {
+		ctx.ReportError(err)
+	}
+}
+
+func invokeEmoji(ctx *OperationContext) error {
+	message := ctx.Message
 	var targetEmoji *discordgo.Emoji
 	if len(message.GetCustomEmojis()) >= 1 {
 		targetEmoji = message.GetCustomEmojis()[0]
 	} else if message.ReferencedMessage != nil && len(message.ReferencedMessage.GetCustomEmojis()) >= 1 {
 		targetEmoji = message.ReferencedMessage.GetCustomEmojis()[0]
 	} else {
-		messages, err := Instance.session.ChannelMessages(message.ChannelID, 20, message.ID, "", "")
+		messages, err := ctx.Session.ChannelMessages(message.ChannelID, 20, message.ID, "", "")
 		if err != nil {
-			log.Error().Err(err).Msg("Error fetching message history")
-			return
+			return withUserMessage(
+				fmt.Errorf("error fetching message history: %w", err),
+				"I couldn't look through earlier messages for an emoji.",
+			)
 		}
 
 		for _, message := range messages {
@@ -308,39 +319,30 @@ func Emoji(message *discordgo.MessageCreate, args EmojiArgs) {
 	}
 
 	if targetEmoji == nil {
-		_, err := Instance.session.ChannelMessageSendReply(
-			message.ChannelID,
-			"No emoji found! Please post the emoji you are looking for and try again, "+
+		return newUserError(
+			"No emoji found! Please post the emoji you are looking for and try again, " +
 				"or retry this command as a reply on the target message.",
-			message.Reference(),
 		)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to send missing emoji error")
-		}
-		return
 	}
 
-	emojiUrl := getEmojiUrl(targetEmoji)
-
-	resp, err := http.Get(emojiUrl)
+	resp, err := Instance.downloader.Get(ctx.Context(), getEmojiUrl(targetEmoji))
 	if err != nil {
-		log.Error().Err(err).Msg("Error downloading emoji")
-		return
+		return withUserMessage(fmt.Errorf("error downloading emoji: %w", err), "I couldn't download that emoji.")
 	}
 	defer closeBody(resp.Body, "Error closing emoji response body")
 
-	_, err = Instance.session.ChannelMessageSendComplex(
-		message.ChannelID,
-		&discordgo.MessageSend{
-			Reference: message.Reference(),
-			File: &discordgo.File{
-				Name:        path.Base(resp.Request.URL.Path),
-				ContentType: resp.Header.Get("Content-Type"),
-				Reader:      resp.Body,
-			},
-		},
-	)
+	input, err := readDownload(resp)
+	if err != nil {
+		return withUserMessage(err, "I couldn't download that emoji.")
+	}
+
+	err = ctx.SendFiles([]*discordgo.File{{
+		Name:        path.Base(resp.Request.URL.Path),
+		ContentType: resp.Header.Get("Content-Type"),
+		Reader:      bytes.NewReader(input),
+	}})
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to send emoji")
+		return withUserMessage(fmt.Errorf("error sending emoji: %w", err), "I couldn't upload the emoji.")
 	}
+	return nil
 }
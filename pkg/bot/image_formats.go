@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// isAPNG reports whether a blob is an animated PNG, by looking for an animation control chunk before the image data.
+func isAPNG(blob []byte) bool {
+	if !bytes.HasPrefix(blob, pngSignature) {
+		return false
+	}
+
+	offset := len(pngSignature)
+	for offset+8 <= len(blob) {
+		length := int(binary.BigEndian.Uint32(blob[offset : offset+4]))
+		switch string(blob[offset+4 : offset+8]) {
+		case "acTL":
+			return true
+		case "IDAT", "IEND":
+			return false
+		}
+		// Skip the chunk's length, type, data and CRC.
+		offset += 12 + length
+	}
+
+	return false
+}
+
+// isAnimatedWebP reports whether a blob is a WebP image with the animation flag set in its extended header.
+func isAnimatedWebP(blob []byte) bool {
+	if len(blob) < 21 || string(blob[0:4]) != "RIFF" || string(blob[8:12]) != "WEBP" {
+		return false
+	}
+	return string(blob[12:16]) == "VP8X" && blob[20]&0x02 != 0
+}
+
+// decodeFilename returns the filename to give ImageMagick when reading a blob. Animated PNGs and WebPs are read
+// as a single frame unless their format is given explicitly.
+func decodeFilename(blob []byte, filename string) string {
+	switch {
+	case isAPNG(blob):
+		return "APNG:" + filename
+	case isAnimatedWebP(blob):
+		return "WEBP:" + filename
+	default:
+		return filename
+	}
+}
+
+// coalesceAPNG coalesces the frames of an animated PNG, honouring the disposal each frame was decoded with. Every
+// coalesced frame is then marked as replacing the last, so that the complete frames aren't layered over each other
+// when they are coalesced again or encoded.
+func coalesceAPNG(wand *imagick.MagickWand) (*imagick.MagickWand, error) {
+	coalesced := wand.CoalesceImages()
+	for i := 0; i < int(coalesced.GetNumberImages()); i++ {
+		coalesced.SetIteratorIndex(i)
+		if err := coalesced.SetImageDispose(imagick.DISPOSE_BACKGROUND); err != nil {
+			coalesced.Destroy()
+			return nil, fmt.Errorf("error configuring disposal: %w", err)
+		}
+	}
+	coalesced.ResetIterator()
+
+	return coalesced, nil
+}
+
+// imageOutputFormat describes an output format that ImageMagick encodes directly.
+type imageOutputFormat struct {
+	magickFormat string
+	extension    string
+}
+
+// pngOutputFormat is used for results with a single frame.
+var pngOutputFormat = imageOutputFormat{magickFormat: "PNG", extension: "png"}
+
+var imageOutputFormats = map[string]imageOutputFormat{
+	outputFormatGIF:  {magickFormat: "GIF", extension: "gif"},
+	outputFormatWebP: {magickFormat: "WEBP", extension: "webp"},
+	// Discord only animates APNGs with a .png extension.
+	outputFormatAPNG: {magickFormat: "APNG", extension: "png"},
+}
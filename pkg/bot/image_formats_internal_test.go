@@ -0,0 +1,169 @@
+package bot
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// pngChunk describes a chunk of a PNG file built for tests.
+type pngChunk struct {
+	chunkType string
+	length    int
+}
+
+// pngWithChunks builds a PNG file from chunks of the given types, each with the given amount of data.
+func pngWithChunks(chunks ...pngChunk) []byte {
+	blob := append([]byte{}, pngSignature...)
+	for _, chunk := range chunks {
+		blob = binary.BigEndian.AppendUint32(blob, uint32(chunk.length))
+		blob = append(blob, chunk.chunkType...)
+		blob = append(blob, make([]byte, chunk.length+4)...)
+	}
+	return blob
+}
+
+// webPWithHeader builds the start of a WebP file with the given first chunk and feature flags.
+func webPWithHeader(chunkType string, flags byte) []byte {
+	blob := []byte("RIFF\x00\x00\x00\x00WEBP" + chunkType + "\x0a\x00\x00\x00")
+	return append(blob, flags, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+}
+
+func TestIsAPNG(t *testing.T) {
+	tests := []struct {
+		name string
+		blob []byte
+		want bool
+	}{
+		{
+			name: "static",
+			blob: pngWithChunks(pngChunk{"IHDR", 13}, pngChunk{"IDAT", 20}, pngChunk{"IEND", 0}),
+			want: false,
+		},
+		{
+			name: "animated",
+			blob: pngWithChunks(pngChunk{"IHDR", 13}, pngChunk{"tEXt", 7}, pngChunk{"acTL", 8}, pngChunk{"IDAT", 20}),
+			want: true,
+		},
+		{
+			name: "animation control after image data",
+			blob: pngWithChunks(pngChunk{"IHDR", 13}, pngChunk{"IDAT", 20}, pngChunk{"acTL", 8}),
+			want: false,
+		},
+		{
+			name: "truncated",
+			blob: pngWithChunks(pngChunk{"IHDR", 13})[:20],
+			want: false,
+		},
+		{name: "signature only", blob: pngSignature, want: false},
+		{name: "not a png", blob: []byte("GIF89a acTL"), want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isAPNG(test.blob); got != test.want {
+				t.Errorf("isAPNG = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsAnimatedWebP(t *testing.T) {
+	tests := []struct {
+		name string
+		blob []byte
+		want bool
+	}{
+		{name: "animated", blob: webPWithHeader("VP8X", 0x02), want: true},
+		{name: "animated with alpha", blob: webPWithHeader("VP8X", 0x12), want: true},
+		{name: "extended but static", blob: webPWithHeader("VP8X", 0x10), want: false},
+		{name: "lossy", blob: webPWithHeader("VP8 ", 0x02), want: false},
+		{name: "lossless", blob: webPWithHeader("VP8L", 0x02), want: false},
+		{name: "truncated", blob: webPWithHeader("VP8X", 0x02)[:20], want: false},
+		{name: "not a webp", blob: []byte("RIFF\x00\x00\x00\x00WAVEVP8X\x0a\x00\x00\x00\x02"), want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isAnimatedWebP(test.blob); got != test.want {
+				t.Errorf("isAnimatedWebP = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// newPositionedFrame creates a frame of a single colour at the given offset on a 4x4 canvas.
+func newPositionedFrame(t *testing.T, colour string, width uint, height uint, x int, y int) *imagick.MagickWand {
+	t.Helper()
+
+	background := imagick.NewPixelWand()
+	defer background.Destroy()
+	background.SetColor(colour)
+
+	frame := imagick.NewMagickWand()
+	t.Cleanup(frame.Destroy)
+	if err := frame.NewImage(width, height, background); err != nil {
+		t.Fatalf("error creating test frame: %v", err)
+	}
+	if err := frame.SetImagePage(4, 4, x, y); err != nil {
+		t.Fatalf("error positioning test frame: %v", err)
+	}
+	return frame
+}
+
+func TestCoalesceAPNGHonoursDisposal(t *testing.T) {
+	tests := []struct {
+		name    string
+		dispose imagick.DisposeType
+		// wantRed is whether the first frame still shows around the second.
+		wantRed bool
+	}{
+		{name: "none", dispose: imagick.DISPOSE_NONE, wantRed: true},
+		{name: "background", dispose: imagick.DISPOSE_BACKGROUND, wantRed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			first := newPositionedFrame(t, "red", 4, 4, 0, 0)
+			if err := first.SetImageDispose(test.dispose); err != nil {
+				t.Fatalf("error setting disposal: %v", err)
+			}
+			second := newPositionedFrame(t, "blue", 2, 2, 2, 2)
+
+			animation := imagick.NewMagickWand()
+			t.Cleanup(animation.Destroy)
+			for _, frame := range []*imagick.MagickWand{first, second} {
+				if err := animation.AddImage(frame); err != nil {
+					t.Fatalf("error adding test frame: %v", err)
+				}
+			}
+
+			coalesced, err := coalesceAPNG(animation)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			t.Cleanup(coalesced.Destroy)
+
+			if frames := coalesced.GetNumberImages(); frames != 2 {
+				t.Fatalf("got %d frames, want 2", frames)
+			}
+			for i := range 2 {
+				coalesced.SetIteratorIndex(i)
+				if dispose := coalesced.GetImageDispose(); dispose != imagick.DISPOSE_BACKGROUND {
+					t.Errorf("frame %d has disposal %v, want background", i, dispose)
+				}
+			}
+
+			coalesced.SetIteratorIndex(1)
+			corner, err := coalesced.GetImagePixelColor(0, 0)
+			if err != nil {
+				t.Fatalf("error reading pixel: %v", err)
+			}
+			defer corner.Destroy()
+			if gotRed := corner.GetRed() == 1 && corner.GetAlpha() == 1; gotRed != test.wantRed {
+				t.Errorf("first frame showing around the second = %v, want %v", gotRed, test.wantRed)
+			}
+		})
+	}
+}
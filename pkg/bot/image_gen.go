@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand/v2"
@@ -48,6 +49,13 @@ func generateImage(ctx *OperationContext, args ImageGenArgs) {
 		return
 	}
 
+	finish, err := ctx.StartJob()
+	if err != nil {
+		ctx.ReportError(err)
+		return
+	}
+	defer finish()
+
 	seed := rand.Int()
 	stableDiffusionOpts := fmt.Sprintf(`<sd_cpp_extra_args>{"seed": %d}</sd_cpp_extra_args>`, seed)
 	finalPrompt := args.Prompt + stableDiffusionOpts
@@ -64,13 +72,15 @@ func generateImage(ctx *OperationContext, args ImageGenArgs) {
 	})
 
 	image, err := Instance.openAiClient.Images.Generate(
-		context.TODO(),
+		ctx.Context(),
 		params,
 	)
 	if err != nil {
-		if sendErr := ctx.SendText("Error generating image: `" + err.Error() + "`"); sendErr != nil {
-			log.Error().Err(sendErr).Msg("Failed to send error response")
-		}
+		ctx.ReportError(withUserMessage(fmt.Errorf("error generating image: %w", err), "I couldn't generate that image."))
+		return
+	}
+	if len(image.Data) == 0 {
+		ctx.ReportError(&UserError{Message: "I couldn't generate that image.", Err: errors.New("no image data returned")})
 		return
 	}
 
@@ -81,7 +91,10 @@ func generateImage(ctx *OperationContext, args ImageGenArgs) {
 	}
 
 	if err := ctx.SendFiles([]*discordgo.File{file}); err != nil {
-		log.Error().Err(err).Msg("Failed to send generated image")
+		ctx.ReportError(withUserMessage(
+			fmt.Errorf("error sending generated image: %w", err),
+			"I couldn't upload the result.",
+		))
 	}
 }
 
@@ -94,6 +107,7 @@ func ImageGenSlashCommand(session *discordgo.Session, interaction *discordgo.Int
 }
 
 func editImage(
+	ctx context.Context,
 	wand *imagick.MagickWand,
 	args ImageEditArgs,
 	metadata AISessionMetadata,
@@ -146,15 +160,15 @@ func editImage(
 	attachSessionMetadata(&params, metadata)
 
 	editedImage, err := Instance.openAiClient.Images.Edit(
-		context.TODO(),
+		ctx,
 		params,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("error editing image: %w", err)
+		return nil, withUserMessage(fmt.Errorf("error editing image: %w", err), "I couldn't edit that image.")
 	}
 
 	if len(editedImage.Data) == 0 {
-		return nil, fmt.Errorf("no image data returned from edit")
+		return nil, &UserError{Message: "I couldn't edit that image.", Err: errors.New("no image data returned from edit")}
 	}
 
 	decodedImg, err := base64.StdEncoding.DecodeString(editedImage.Data[0].B64JSON)
@@ -173,19 +187,31 @@ func editImage(
 
 type ImageEditArgs struct {
 	Prompt   string `description:"Prompt to edit the image with."`
-	ImageURL string `default:"" description:"URL of the image to edit."`
+	ImageURL string `default:"" cache:"-" description:"URL of the image to edit, #N to pick one from a message, or #all for each. Blank to auto-find."`
+	Seed     int    `default:"0" description:"Seed to use for generation. Leave as 0 to pick one at random."`
 }
 
 func (args ImageEditArgs) GetImageURL() string {
 	return args.ImageURL
 }
 
+// ProcessFramesSequentially keeps each AI job, this one and those below, to one request to the image editing backend
+// at a time.
+func (args ImageEditArgs) ProcessFramesSequentially() bool {
+	return true
+}
+
+func (args ImageEditArgs) GetSeed() int {
+	return args.Seed
+}
+
 func ImageEdit(
+	ctx context.Context,
 	wand *imagick.MagickWand,
 	args ImageEditArgs,
 	metadata AISessionMetadata,
 ) ([]*imagick.MagickWand, error) {
-	editedImage, err := editImage(wand, args, metadata, nil)
+	editedImage, err := editImage(ctx, wand, args, metadata, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -195,28 +221,43 @@ func ImageEdit(
 
 type LoopEditArgs struct {
 	Prompt   string `description:"Prompt to edit the image with."`
-	ImageURL string `default:"" description:"URL of the image to edit."`
+	ImageURL string `default:"" cache:"-" description:"URL of the image to edit, #N to pick one from a message, or #all for each. Blank to auto-find."`
 	Steps    uint   `default:"4" description:"Number of edit iterations to perform."`
+	Seed     int    `default:"0" description:"Seed to use for generation. Leave as 0 to pick one at random."`
 }
 
 func (args LoopEditArgs) GetImageURL() string {
 	return args.ImageURL
 }
 
-func LoopEdit(wand *imagick.MagickWand, args LoopEditArgs, metadata AISessionMetadata) ([]*imagick.MagickWand, error) {
+func (args LoopEditArgs) ProcessFramesSequentially() bool {
+	return true
+}
+
+func (args LoopEditArgs) GetSeed() int {
+	return args.Seed
+}
+
+func LoopEdit(
+	ctx context.Context,
+	wand *imagick.MagickWand,
+	args LoopEditArgs,
+	metadata AISessionMetadata,
+) ([]*imagick.MagickWand, error) {
 	editedFrames := make([]*imagick.MagickWand, 0, args.Steps)
 
 	currentWand := wand
 	var err error
-	for range args.Steps {
+	for step := range args.Steps {
 		metadata.Seed++ // Increment the seed for each iteration to produce different results
-		currentWand, err = editImage(currentWand, ImageEditArgs{
+		currentWand, err = editImage(ctx, currentWand, ImageEditArgs{
 			Prompt: args.Prompt,
 		}, metadata, nil)
 		if err != nil {
 			return nil, err
 		}
 		editedFrames = append(editedFrames, currentWand)
+		ReportProgress(ctx, fmt.Sprintf("AI step %d/%d", step+1, args.Steps), currentWand)
 	}
 
 	return editedFrames, nil
@@ -225,53 +266,80 @@ func LoopEdit(wand *imagick.MagickWand, args LoopEditArgs, metadata AISessionMet
 type FlipFlopArgs struct {
 	Prompt1  string `description:"First prompt to edit the image with."`
 	Prompt2  string `description:"Second prompt to edit the image with."`
-	ImageURL string `default:"" description:"URL of the image to edit."`
+	ImageURL string `default:"" cache:"-" description:"URL of the image to edit, #N to pick one from a message, or #all for each. Blank to auto-find."`
 	Steps    uint   `default:"4" description:"Number of edit iterations to perform."`
+	Seed     int    `default:"0" description:"Seed to use for generation. Leave as 0 to pick one at random."`
 }
 
 func (args FlipFlopArgs) GetImageURL() string {
 	return args.ImageURL
 }
 
-func FlipFlop(wand *imagick.MagickWand, args FlipFlopArgs, metadata AISessionMetadata) ([]*imagick.MagickWand, error) {
+func (args FlipFlopArgs) ProcessFramesSequentially() bool {
+	return true
+}
+
+func (args FlipFlopArgs) GetSeed() int {
+	return args.Seed
+}
+
+func FlipFlop(
+	ctx context.Context,
+	wand *imagick.MagickWand,
+	args FlipFlopArgs,
+	metadata AISessionMetadata,
+) ([]*imagick.MagickWand, error) {
 	editedFrames := make([]*imagick.MagickWand, 0, args.Steps*2+1)
 
 	editedFrames = append(editedFrames, wand)
 
 	currentWand := wand
 	var err error
-	for range args.Steps {
+	for step := range args.Steps {
 		metadata.Seed++ // Increment the seed for each iteration to produce different results
-		currentWand, err = editImage(currentWand, ImageEditArgs{
+		currentWand, err = editImage(ctx, currentWand, ImageEditArgs{
 			Prompt: args.Prompt1,
 		}, metadata, nil)
 		if err != nil {
 			return nil, err
 		}
 		editedFrames = append(editedFrames, currentWand)
-		currentWand, err = editImage(currentWand, ImageEditArgs{
+		ReportProgress(ctx, fmt.Sprintf("AI step %d/%d", step*2+1, args.Steps*2), currentWand)
+
+		currentWand, err = editImage(ctx, currentWand, ImageEditArgs{
 			Prompt: args.Prompt2,
 		}, metadata, nil)
 		if err != nil {
 			return nil, err
 		}
 		editedFrames = append(editedFrames, currentWand)
+		ReportProgress(ctx, fmt.Sprintf("AI step %d/%d", step*2+2, args.Steps*2), currentWand)
 	}
 
 	return editedFrames, nil
 }
 
 type AiZoomArgs struct {
-	ImageURL string `default:"" description:"URL of the image to edit."`
+	ImageURL string `default:"" cache:"-" description:"URL of the image to edit, #N to pick one from a message, or #all for each. Blank to auto-find."`
 	Prompt   string `default:"Expand the image outwards." description:"Prompt to edit the image with."`
 	Steps    uint   `default:"2" description:"Number of zoom steps to perform."`
+	Seed     int    `default:"0" description:"Seed to use for generation. Leave as 0 to pick one at random."`
 }
 
 func (args AiZoomArgs) GetImageURL() string {
 	return args.ImageURL
 }
 
+func (args AiZoomArgs) ProcessFramesSequentially() bool {
+	return true
+}
+
+func (args AiZoomArgs) GetSeed() int {
+	return args.Seed
+}
+
 func performAiZoomStep(
+	ctx context.Context,
 	wand *imagick.MagickWand,
 	prompt string,
 	metadata AISessionMetadata,
@@ -336,7 +404,7 @@ func performAiZoomStep(
 		return nil, fmt.Errorf("error negating mask for zoom: %w", err)
 	}
 
-	editedImage, err := editImage(canvas, ImageEditArgs{
+	editedImage, err := editImage(ctx, canvas, ImageEditArgs{
 		Prompt: prompt,
 	}, metadata, mask)
 	if err != nil {
@@ -346,30 +414,46 @@ func performAiZoomStep(
 	return editedImage, nil
 }
 
-func AiZoom(wand *imagick.MagickWand, args AiZoomArgs, metadata AISessionMetadata) ([]*imagick.MagickWand, error) {
+func AiZoom(
+	ctx context.Context,
+	wand *imagick.MagickWand,
+	args AiZoomArgs,
+	metadata AISessionMetadata,
+) ([]*imagick.MagickWand, error) {
 	var err error
 
-	for range args.Steps {
-		wand, err = performAiZoomStep(wand, args.Prompt, metadata)
+	for step := range args.Steps {
+		wand, err = performAiZoomStep(ctx, wand, args.Prompt, metadata)
 		if err != nil {
 			return nil, err
 		}
+		ReportProgress(ctx, fmt.Sprintf("AI step %d/%d", step+1, args.Steps), wand)
 	}
 
 	return []*imagick.MagickWand{wand}, nil
 }
 
 type AiLoopZoomArgs struct {
-	ImageURL string `default:"" description:"URL of the image to edit."`
+	ImageURL string `default:"" cache:"-" description:"URL of the image to edit, #N to pick one from a message, or #all for each. Blank to auto-find."`
 	Prompt   string `default:"Expand the image outwards." description:"Prompt to edit the image with."`
 	Steps    uint   `default:"5" description:"Number of zoom steps to perform."`
+	Seed     int    `default:"0" description:"Seed to use for generation. Leave as 0 to pick one at random."`
 }
 
 func (args AiLoopZoomArgs) GetImageURL() string {
 	return args.ImageURL
 }
 
+func (args AiLoopZoomArgs) ProcessFramesSequentially() bool {
+	return true
+}
+
+func (args AiLoopZoomArgs) GetSeed() int {
+	return args.Seed
+}
+
 func AiLoopZoom(
+	ctx context.Context,
 	wand *imagick.MagickWand,
 	args AiLoopZoomArgs,
 	metadata AISessionMetadata,
@@ -380,13 +464,14 @@ func AiLoopZoom(
 
 	var err error
 
-	for range args.Steps {
+	for step := range args.Steps {
 		wand = wand.Clone()
-		wand, err = performAiZoomStep(wand, args.Prompt, metadata)
+		wand, err = performAiZoomStep(ctx, wand, args.Prompt, metadata)
 		if err != nil {
 			return nil, err
 		}
 		editedFrames = append(editedFrames, wand)
+		ReportProgress(ctx, fmt.Sprintf("AI step %d/%d", step+1, args.Steps), wand)
 	}
 
 	minWidth := editedFrames[0].GetImageWidth()
@@ -0,0 +1,39 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// customIDPrefix returns the part of a component or modal's custom ID that says what created it, such as result.
+func customIDPrefix(customID string) string {
+	prefix, _, _ := strings.Cut(customID, ":")
+	return prefix
+}
+
+// handleInteraction routes each interaction to the handler for the command, component or modal it's for.
+func handleInteraction(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	switch interaction.Type {
+	case discordgo.InteractionApplicationCommand:
+		switch interaction.ApplicationCommandData().CommandType {
+		case discordgo.ChatApplicationCommand:
+			handleSlashCommand(session, interaction)
+		case discordgo.MessageApplicationCommand:
+			handleContextMenuCommand(session, interaction)
+		}
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		handleAutocomplete(session, interaction)
+	case discordgo.InteractionMessageComponent:
+		switch customIDPrefix(interaction.MessageComponentData().CustomID) {
+		case resultActionPrefix:
+			handleResultComponent(session, interaction)
+		case helpPrefix:
+			handleHelpComponent(session, interaction)
+		}
+	case discordgo.InteractionModalSubmit:
+		if customIDPrefix(interaction.ModalSubmitData().CustomID) == contextMenuPrefix {
+			handleContextMenuModal(session, interaction)
+		}
+	}
+}
@@ -1,13 +1,14 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
 
 type InvertArgs struct {
-	ImageURL string `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL string `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 }
 
 func (args InvertArgs) GetImageURL() string {
@@ -15,7 +16,7 @@ func (args InvertArgs) GetImageURL() string {
 }
 
 // Invert inverts an image's colours.
-func Invert(wand *imagick.MagickWand, _ InvertArgs) ([]*imagick.MagickWand, error) {
+func Invert(_ context.Context, wand *imagick.MagickWand, _ InvertArgs) ([]*imagick.MagickWand, error) {
 	wand.SetImageChannelMask(imagick.CHANNEL_RED | imagick.CHANNEL_GREEN | imagick.CHANNEL_BLUE)
 	err := wand.NegateImage(false)
 	if err != nil {
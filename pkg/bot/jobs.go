@@ -0,0 +1,281 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+
+	configPkg "github.com/fogo-sh/borik/pkg/config"
+)
+
+var errQueueFull = newUserError("I'm too busy right now - please try again in a little while.")
+
+// queuedJob is a job waiting for a slot in the JobQueue.
+type queuedJob struct {
+	userID     string
+	guildID    string
+	ready      chan struct{}
+	onPosition func(ahead int)
+	position   int
+}
+
+// positionUpdate is a pending notification of a job's new position in the queue.
+type positionUpdate struct {
+	job   *queuedJob
+	ahead int
+}
+
+// JobQueue limits how many image jobs can run at once, both globally and for each user and guild.
+// Jobs that cannot start immediately wait in order of arrival.
+type JobQueue struct {
+	mu sync.Mutex
+
+	workers   int
+	perUser   int
+	perGuild  int
+	maxQueued int
+
+	running        int
+	runningByUser  map[string]int
+	runningByGuild map[string]int
+	waiting        []*queuedJob
+}
+
+// NewJobQueue constructs a new JobQueue based on the provided config.
+func NewJobQueue(config *configPkg.Config) *JobQueue {
+	workers := config.JobWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	return &JobQueue{
+		workers:        workers,
+		perUser:        config.JobsPerUser,
+		perGuild:       config.JobsPerGuild,
+		maxQueued:      config.JobQueueSize,
+		runningByUser:  map[string]int{},
+		runningByGuild: map[string]int{},
+	}
+}
+
+// canStart reports whether a job is permitted to start given the jobs that are currently running.
+func (q *JobQueue) canStart(job *queuedJob) bool {
+	if q.running >= q.workers {
+		return false
+	}
+	if q.perUser > 0 && q.runningByUser[job.userID] >= q.perUser {
+		return false
+	}
+	if q.perGuild > 0 && job.guildID != "" && q.runningByGuild[job.guildID] >= q.perGuild {
+		return false
+	}
+	return true
+}
+
+// dispatchLocked starts every waiting job that is able to start, returning the position updates for jobs still waiting.
+// The caller must hold q.mu.
+func (q *JobQueue) dispatchLocked() []positionUpdate {
+	var updates []positionUpdate
+	remaining := q.waiting[:0]
+
+	for _, job := range q.waiting {
+		if q.canStart(job) {
+			q.running++
+			q.runningByUser[job.userID]++
+			if job.guildID != "" {
+				q.runningByGuild[job.guildID]++
+			}
+			close(job.ready)
+			continue
+		}
+
+		ahead := len(remaining)
+		if ahead != job.position {
+			job.position = ahead
+			updates = append(updates, positionUpdate{job, ahead})
+		}
+		remaining = append(remaining, job)
+	}
+
+	q.waiting = remaining
+	return updates
+}
+
+func notifyPositions(updates []positionUpdate) {
+	for _, update := range updates {
+		if update.job.onPosition != nil {
+			update.job.onPosition(update.ahead)
+		}
+	}
+}
+
+// Acquire waits until a job for the given user and guild is permitted to start, or until ctx is done.
+// onPosition is called with the number of jobs ahead whenever the job's position in the queue changes.
+// The returned function must be called once the job has finished.
+func (q *JobQueue) Acquire(
+	ctx context.Context,
+	userID string,
+	guildID string,
+	onPosition func(ahead int),
+) (func(), error) {
+	job := &queuedJob{
+		userID:     userID,
+		guildID:    guildID,
+		ready:      make(chan struct{}),
+		onPosition: onPosition,
+		position:   -1,
+	}
+
+	q.mu.Lock()
+	if q.maxQueued > 0 && len(q.waiting) >= q.maxQueued {
+		q.mu.Unlock()
+		return nil, errQueueFull
+	}
+	q.waiting = append(q.waiting, job)
+	updates := q.dispatchLocked()
+	q.mu.Unlock()
+
+	notifyPositions(updates)
+
+	select {
+	case <-job.ready:
+	case <-ctx.Done():
+		if !q.abandon(job) {
+			// The job was started before it could be removed from the queue, so give its slot back.
+			q.release(job)
+		}
+		return nil, context.Cause(ctx)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { q.release(job) })
+	}, nil
+}
+
+// abandon removes a job from the queue, returning false if it had already been started.
+func (q *JobQueue) abandon(job *queuedJob) bool {
+	q.mu.Lock()
+	index := slices.Index(q.waiting, job)
+	if index == -1 {
+		q.mu.Unlock()
+		return false
+	}
+	q.waiting = slices.Delete(q.waiting, index, index+1)
+	updates := q.dispatchLocked()
+	q.mu.Unlock()
+
+	notifyPositions(updates)
+	return true
+}
+
+func (q *JobQueue) release(job *queuedJob) {
+	q.mu.Lock()
+	q.running--
+	q.runningByUser[job.userID]--
+	if q.runningByUser[job.userID] <= 0 {
+		delete(q.runningByUser, job.userID)
+	}
+	if job.guildID != "" {
+		q.runningByGuild[job.guildID]--
+		if q.runningByGuild[job.guildID] <= 0 {
+			delete(q.runningByGuild, job.guildID)
+		}
+	}
+	updates := q.dispatchLocked()
+	q.mu.Unlock()
+
+	notifyPositions(updates)
+}
+
+// queueStatus shows a requester their position in the job queue.
+type queueStatus struct {
+	ctx     *OperationContext
+	mu      sync.Mutex
+	message *discordgo.Message
+	shown   bool
+	done    bool
+}
+
+func queuePositionText(ahead int) string {
+	switch ahead {
+	case 0:
+		return "Queued, you're next."
+	case 1:
+		return "Queued, 1 job ahead of you."
+	default:
+		return fmt.Sprintf("Queued, %d jobs ahead of you.", ahead)
+	}
+}
+
+func (s *queueStatus) update(ahead int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return
+	}
+
+	content := queuePositionText(ahead)
+	var err error
+
+	switch {
+	case s.ctx.Message == nil:
+		_, err = s.ctx.Session.InteractionResponseEdit(s.ctx.Interaction.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+	case s.message == nil:
+		s.message, err = s.ctx.Session.ChannelMessageSendReply(s.ctx.Message.ChannelID, content, s.ctx.Message.Reference())
+	default:
+		_, err = s.ctx.Session.ChannelMessageEdit(s.message.ChannelID, s.message.ID, content)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to update queue position")
+		return
+	}
+	s.shown = true
+}
+
+// clear removes the queue position once the job has left the queue.
+func (s *queueStatus) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.done = true
+	if !s.shown {
+		return
+	}
+
+	var err error
+	if s.message != nil {
+		err = s.ctx.Session.ChannelMessageDelete(s.message.ChannelID, s.message.ID)
+	} else if s.ctx.Interaction != nil {
+		content := "Processing..."
+		_, err = s.ctx.Session.InteractionResponseEdit(s.ctx.Interaction.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to clear queue position")
+	}
+}
+
+// WaitForJobSlot waits until this operation is permitted to run, keeping the requester informed of their position
+// in the queue. The returned function must be called once the operation has finished.
+func (ctx *OperationContext) WaitForJobSlot(jobCtx context.Context) (func(), error) {
+	status := &queueStatus{ctx: ctx}
+
+	release, err := Instance.jobs.Acquire(jobCtx, ctx.GetUserID(), ctx.GetGuildID(), status.update)
+	status.clear()
+	if err != nil {
+		return nil, err
+	}
+
+	return release, nil
+}
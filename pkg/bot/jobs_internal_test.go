@@ -0,0 +1,211 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	configPkg "github.com/fogo-sh/borik/pkg/config"
+)
+
+// acquireResult is the outcome of an Acquire call made in the background.
+type acquireResult struct {
+	release func()
+	err     error
+}
+
+// acquireAsync calls Acquire in the background, returning a channel that receives its outcome.
+func acquireAsync(ctx context.Context, q *JobQueue, userID string, guildID string) <-chan acquireResult {
+	result := make(chan acquireResult, 1)
+	go func() {
+		release, err := q.Acquire(ctx, userID, guildID, nil)
+		result <- acquireResult{release, err}
+	}()
+	return result
+}
+
+// mustAcquire acquires a slot that is expected to be available immediately.
+func mustAcquire(t *testing.T, q *JobQueue, userID string, guildID string) func() {
+	t.Helper()
+	release, err := q.Acquire(context.Background(), userID, guildID, nil)
+	if err != nil {
+		t.Fatalf("unexpected error acquiring a slot for %s: %v", userID, err)
+	}
+	return release
+}
+
+// waitForQueued waits until the given number of jobs are waiting in the queue.
+func waitForQueued(t *testing.T, q *JobQueue, count int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		q.mu.Lock()
+		waiting := len(q.waiting)
+		q.mu.Unlock()
+		if waiting == count {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d queued jobs", count)
+}
+
+// expectStarted waits for a job acquired in the background to start.
+func expectStarted(t *testing.T, result <-chan acquireResult) func() {
+	t.Helper()
+	select {
+	case outcome := <-result:
+		if outcome.err != nil {
+			t.Fatalf("unexpected error: %v", outcome.err)
+		}
+		return outcome.release
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the job to start")
+		return nil
+	}
+}
+
+// expectWaiting checks that a job acquired in the background hasn't started.
+func expectWaiting(t *testing.T, result <-chan acquireResult) {
+	t.Helper()
+	select {
+	case outcome := <-result:
+		t.Fatalf("expected the job to wait, but it finished with error %v", outcome.err)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestJobQueuePerUserLimit(t *testing.T) {
+	q := NewJobQueue(&configPkg.Config{JobWorkers: 4, JobsPerUser: 1})
+
+	first := mustAcquire(t, q, "alice", "")
+	second := acquireAsync(context.Background(), q, "alice", "")
+	waitForQueued(t, q, 1)
+	expectWaiting(t, second)
+
+	// Another user can start even though alice's job is ahead of them in the queue.
+	other := mustAcquire(t, q, "bob", "")
+	expectWaiting(t, second)
+
+	first()
+	expectStarted(t, second)()
+	other()
+}
+
+func TestJobQueuePerGuildLimit(t *testing.T) {
+	q := NewJobQueue(&configPkg.Config{JobWorkers: 4, JobsPerGuild: 2})
+
+	first := mustAcquire(t, q, "alice", "guild")
+	second := mustAcquire(t, q, "bob", "guild")
+	third := acquireAsync(context.Background(), q, "carol", "guild")
+	waitForQueued(t, q, 1)
+	expectWaiting(t, third)
+
+	// Jobs in other guilds, and outside of guilds, aren't held back.
+	mustAcquire(t, q, "carol", "other guild")()
+	mustAcquire(t, q, "carol", "")()
+
+	second()
+	expectStarted(t, third)()
+	first()
+}
+
+func TestJobQueueServesJobsInOrder(t *testing.T) {
+	q := NewJobQueue(&configPkg.Config{JobWorkers: 1})
+
+	hold := mustAcquire(t, q, "holder", "")
+	users := []string{"alice", "bob", "carol"}
+	results := make([]<-chan acquireResult, len(users))
+	for index, user := range users {
+		results[index] = acquireAsync(context.Background(), q, user, "")
+		waitForQueued(t, q, index+1)
+	}
+
+	hold()
+	for index := range users {
+		release := expectStarted(t, results[index])
+		for _, later := range results[index+1:] {
+			expectWaiting(t, later)
+		}
+		release()
+	}
+}
+
+func TestJobQueueReportsPositions(t *testing.T) {
+	q := NewJobQueue(&configPkg.Config{JobWorkers: 1})
+	hold := mustAcquire(t, q, "holder", "")
+
+	firstPositions := make(chan int, 10)
+	secondPositions := make(chan int, 10)
+	go func() {
+		release, err := q.Acquire(context.Background(), "alice", "", func(ahead int) { firstPositions <- ahead })
+		if err == nil {
+			release()
+		}
+	}()
+	waitForQueued(t, q, 1)
+	second := make(chan func(), 1)
+	go func() {
+		release, err := q.Acquire(context.Background(), "bob", "", func(ahead int) { secondPositions <- ahead })
+		if err == nil {
+			second <- release
+		}
+	}()
+	waitForQueued(t, q, 2)
+
+	if ahead := <-firstPositions; ahead != 0 {
+		t.Errorf("first job has %d ahead, want 0", ahead)
+	}
+	if ahead := <-secondPositions; ahead != 1 {
+		t.Errorf("second job has %d ahead, want 1", ahead)
+	}
+
+	hold()
+	(<-second)()
+	if ahead := <-secondPositions; ahead != 0 {
+		t.Errorf("second job has %d ahead once the first started, want 0", ahead)
+	}
+}
+
+func TestJobQueueFull(t *testing.T) {
+	q := NewJobQueue(&configPkg.Config{JobWorkers: 1, JobQueueSize: 1})
+
+	hold := mustAcquire(t, q, "holder", "")
+	queued := acquireAsync(context.Background(), q, "alice", "")
+	waitForQueued(t, q, 1)
+
+	if _, err := q.Acquire(context.Background(), "bob", "", nil); !errors.Is(err, errQueueFull) {
+		t.Errorf("got error %v, want errQueueFull", err)
+	}
+
+	hold()
+	expectStarted(t, queued)()
+}
+
+func TestJobQueueCancelledWhileQueued(t *testing.T) {
+	q := NewJobQueue(&configPkg.Config{JobWorkers: 1})
+
+	hold := mustAcquire(t, q, "holder", "")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := acquireAsync(ctx, q, "alice", "")
+	waitForQueued(t, q, 1)
+
+	cancel()
+	select {
+	case outcome := <-cancelled:
+		if !errors.Is(outcome.err, context.Canceled) {
+			t.Errorf("got error %v, want context.Canceled", outcome.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the cancelled job to give up")
+	}
+	waitForQueued(t, q, 0)
+
+	hold()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.running != 0 || len(q.runningByUser) != 0 {
+		t.Errorf("slots were not returned: %d running, %v by user", q.running, q.runningByUser)
+	}
+}
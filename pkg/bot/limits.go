@@ -0,0 +1,76 @@
+package bot
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/gographics/imagick.v3/imagick"
+
+	configPkg "github.com/fogo-sh/borik/pkg/config"
+)
+
+// configureResourceLimits applies the configured ImageMagick resource limits.
+// ImageMagick's time limit isn't used, as it covers the lifetime of the whole process and exits it once reached;
+// operations are bounded by the timeouts of their jobs instead.
+func configureResourceLimits(config *configPkg.Config) {
+	limits := []struct {
+		name     string
+		resource imagick.ResourceType
+		limit    uint64
+	}{
+		{"memory", imagick.RESOURCE_MEMORY, config.ImagemagickMemoryLimit},
+		{"map", imagick.RESOURCE_MAP, config.ImagemagickMapLimit},
+		{"disk", imagick.RESOURCE_DISK, config.ImagemagickDiskLimit},
+	}
+
+	for _, limit := range limits {
+		if limit.limit == 0 {
+			continue
+		}
+		if !imagick.SetResourceLimit(limit.resource, limit.limit) {
+			log.Warn().Str("resource", limit.name).Uint64("limit", limit.limit).Msg("Failed to set ImageMagick resource limit")
+		}
+	}
+}
+
+// checkImageLimits pings an image to read its headers, ensuring it is within the configured limits
+// before it is decoded.
+func checkImageLimits(blob []byte, filename string) error {
+	ping := imagick.NewMagickWand()
+	defer ping.Destroy()
+
+	if err := ping.SetFilename(filename); err != nil {
+		log.Error().Err(err).Msg("Failed to set image filename - pinging may not behave as expected.")
+	}
+	if err := ping.PingImageBlob(blob); err != nil {
+		return fmt.Errorf("error pinging image: %w", err)
+	}
+
+	frames := ping.GetNumberImages()
+	if maxFrames := Instance.config.MaxInputFrames; maxFrames != 0 && frames > maxFrames {
+		return newUserError("That image has %d frames, but I can only process up to %d.", frames, maxFrames)
+	}
+
+	var totalPixels uint64
+	for i := 0; i < int(frames); i++ {
+		ping.SetIteratorIndex(i)
+		width, height := ping.GetImageWidth(), ping.GetImageHeight()
+
+		pageWidth, pageHeight, _, _, err := ping.GetImagePage()
+		if err == nil {
+			width, height = max(width, pageWidth), max(height, pageHeight)
+		}
+
+		totalPixels += uint64(width) * uint64(height)
+	}
+
+	if maxPixels := Instance.config.MaxInputPixels; maxPixels != 0 && totalPixels > maxPixels {
+		return newUserError(
+			"That image is too large to process (%.1f megapixels across all frames, the limit is %.1f).",
+			float64(totalPixels)/1_000_000,
+			float64(maxPixels)/1_000_000,
+		)
+	}
+
+	return nil
+}
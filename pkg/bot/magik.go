@@ -1,14 +1,15 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
 
 type MagikArgs struct {
-	ImageURL         string  `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
-	Scale            float64 `default:"1" description:"Scale of the magikification. Larger numbers produce more destroyed images."`
+	ImageURL         string  `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
+	Scale            float64 `default:"1" primary:"true" description:"Scale of the magikification. Larger numbers produce more destroyed images."`
 	WidthMultiplier  float64 `default:"0.5" description:"Multiplier to apply to the width of the input image to produce the intermediary image."`
 	HeightMultiplier float64 `default:"0.5" description:"Multiplier to apply to the height of the input image to produce the intermediary image."`
 }
@@ -40,12 +41,12 @@ func magikHelper(wand *imagick.MagickWand, args MagikArgs) ([]*imagick.MagickWan
 }
 
 // Magik runs content-aware scaling on an image.
-func Magik(wand *imagick.MagickWand, args MagikArgs) ([]*imagick.MagickWand, error) {
+func Magik(_ context.Context, wand *imagick.MagickWand, args MagikArgs) ([]*imagick.MagickWand, error) {
 	return magikHelper(wand, args)
 }
 
 type LagikArgs struct {
-	ImageURL string  `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL string  `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 	Scale    float64 `default:"1" description:"Scale of the magikification. Larger numbers produce more destroyed images."`
 }
 
@@ -54,7 +55,7 @@ func (args LagikArgs) GetImageURL() string {
 }
 
 // Lagik runs content-aware scaling on an image.
-func Lagik(wand *imagick.MagickWand, args LagikArgs) ([]*imagick.MagickWand, error) {
+func Lagik(_ context.Context, wand *imagick.MagickWand, args LagikArgs) ([]*imagick.MagickWand, error) {
 	return magikHelper(
 		wand,
 		MagikArgs{
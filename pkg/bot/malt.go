@@ -1,14 +1,15 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
 
 type MaltArgs struct {
-	ImageURL string  `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
-	Degree   float64 `default:"45" description:"Number of degrees to rotate the image by while processing."`
+	ImageURL string  `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
+	Degree   float64 `default:"45" primary:"true" description:"Number of degrees to rotate the image by while processing."`
 }
 
 func (args MaltArgs) GetImageURL() string {
@@ -16,7 +17,7 @@ func (args MaltArgs) GetImageURL() string {
 }
 
 // Malt mixes an image via a combination of operations.
-func Malt(wand *imagick.MagickWand, args MaltArgs) ([]*imagick.MagickWand, error) {
+func Malt(_ context.Context, wand *imagick.MagickWand, args MaltArgs) ([]*imagick.MagickWand, error) {
 	width := wand.GetImageWidth()
 	height := wand.GetImageHeight()
 
@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/nint8835/parsley"
+	"github.com/rs/zerolog/log"
+)
+
+// mediaPickPrefix starts an argument picking a piece of media from a message by its position, such as #3.
+const mediaPickPrefix = "#"
+
+// mediaPickAll can be given in place of an image URL to process every piece of media in a message separately.
+const mediaPickAll = "#all"
+
+// maxPickedMedia is the most pieces of media that will be processed from a single message with mediaPickAll.
+const maxPickedMedia = 10
+
+// parseMediaPick parses an argument picking a piece of media by its position, returning its zero-based index.
+func parseMediaPick(arg string) (int, bool) {
+	number, ok := strings.CutPrefix(arg, mediaPickPrefix)
+	if !ok {
+		return 0, false
+	}
+	position, err := strconv.Atoi(number)
+	if err != nil || position < 1 {
+		return 0, false
+	}
+	return position - 1, true
+}
+
+// escapeMediaPicks escapes each unquoted # at the start of a word in a command, so that picks like #3 are passed
+// through as arguments rather than being treated as the start of a comment.
+func escapeMediaPicks(content string) string {
+	var escaped strings.Builder
+	var quote rune
+	startOfWord, escaping := true, false
+
+	for _, r := range content {
+		switch {
+		case escaping:
+			escaping = false
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\\':
+			escaping = true
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && startOfWord:
+			escaped.WriteRune('\\')
+		}
+
+		escaped.WriteRune(r)
+		startOfWord = quote == 0 && !escaping && (r == ' ' || r == '\t' || r == '\n' || r == '\r')
+	}
+
+	return escaped.String()
+}
+
+// makeTextCommandHandler creates a handler running the text command in each message with the given parser,
+// reporting any error parsing it.
+func makeTextCommandHandler(parser *parsley.Parser) func(*discordgo.Session, *discordgo.MessageCreate) {
+	return func(session *discordgo.Session, message *discordgo.MessageCreate) {
+		escaped := *message.Message
+		escaped.Content = escapeMediaPicks(message.Content)
+
+		if err := parser.RunCommand(&discordgo.MessageCreate{Message: &escaped}); err != nil {
+			_, err = session.ChannelMessageSend(
+				message.ChannelID,
+				fmt.Sprintf("An error occurred running your command:\n```\n%s\n```", err.Error()),
+			)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to send error message")
+			}
+		}
+	}
+}
+
+// findMessageMedia returns every piece of media of the given kind in the nearest message that has any, looking at
+// the message that invoked the operation, then the message it replies to, then recent messages in the channel.
+func (ctx *OperationContext) findMessageMedia(kind mediaType) ([]string, error) {
+	beforeID := ""
+	if ctx.Message != nil {
+		if urls := mediaURLsFromMessage(ctx.Message.Message, kind); len(urls) > 0 {
+			return urls, nil
+		}
+		if ctx.Message.ReferencedMessage != nil {
+			if urls := mediaURLsFromMessage(ctx.Message.ReferencedMessage, kind); len(urls) > 0 {
+				return urls, nil
+			}
+		}
+		beforeID = ctx.Message.ID
+	}
+
+	messages, err := ctx.Session.ChannelMessages(ctx.GetChannelID(), 20, beforeID, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving message history: %w", err)
+	}
+	for _, message := range messages {
+		if urls := mediaURLsFromMessage(message, kind); len(urls) > 0 {
+			return urls, nil
+		}
+	}
+	return nil, fmt.Errorf("unable to locate a %s", kind.name)
+}
+
+// pickMediaURL returns the piece of media at the given position in the nearest message that has any.
+func (ctx *OperationContext) pickMediaURL(index int, kind mediaType) (string, error) {
+	urls, err := ctx.findMessageMedia(kind)
+	if err != nil {
+		return "", withUserMessage(err, fmt.Sprintf("I couldn't find a message to pick #%d from.", index+1))
+	}
+	if index >= len(urls) {
+		return "", newUserError("I can only see %d in that message, so I can't pick #%d.", len(urls), index+1)
+	}
+	return urls[index], nil
+}
+
+// resolveMediaURL resolves the media argument of an operation to a URL, following the last result shortcut,
+// picks like #3 and references to media on Discord, and locating media automatically when the argument is blank.
+func (ctx *OperationContext) resolveMediaURL(arg string, kind mediaType) (string, error) {
+	if mediaURL, ok, err := ctx.resolveMediaSource(arg, kind); ok {
+		return mediaURL, err
+	}
+	if arg == lastResultShortcut {
+		return ctx.resolveLastResult()
+	}
+	if index, ok := parseMediaPick(arg); ok {
+		return ctx.pickMediaURL(index, kind)
+	}
+	if arg == "" {
+		return ctx.findMediaURL(kind)
+	}
+	return arg, nil
+}
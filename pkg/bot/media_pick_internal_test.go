@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"testing"
+)
+
+func TestParseMediaPick(t *testing.T) {
+	tests := []struct {
+		arg       string
+		wantIndex int
+		wantOK    bool
+	}{
+		{arg: "#1", wantIndex: 0, wantOK: true},
+		{arg: "#3", wantIndex: 2, wantOK: true},
+		{arg: "#12", wantIndex: 11, wantOK: true},
+		{arg: "#0", wantOK: false},
+		{arg: "#-1", wantOK: false},
+		{arg: "#", wantOK: false},
+		{arg: "#all", wantOK: false},
+		{arg: "#2x", wantOK: false},
+		{arg: "3", wantOK: false},
+		{arg: "https://example.com/#1", wantOK: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.arg, func(t *testing.T) {
+			index, ok := parseMediaPick(test.arg)
+			if ok != test.wantOK || (ok && index != test.wantIndex) {
+				t.Errorf("parseMediaPick(%q) = (%d, %v), want (%d, %v)", test.arg, index, ok, test.wantIndex, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestEscapeMediaPicks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "no picks", content: "borik!magik 2", want: "borik!magik 2"},
+		{name: "pick", content: "borik!magik #2", want: `borik!magik \#2`},
+		{name: "all", content: "borik!magik #all 2", want: `borik!magik \#all 2`},
+		{name: "after tab", content: "borik!magik\t#2", want: "borik!magik\t\\#2"},
+		{name: "several picks", content: "borik!blend #1 #2", want: `borik!blend \#1 \#2`},
+		{name: "inside a word", content: "borik!magik https://example.com/#2", want: "borik!magik https://example.com/#2"},
+		{name: "double quoted", content: `borik!meme "#1 meme"`, want: `borik!meme "#1 meme"`},
+		{name: "single quoted", content: `borik!meme '#1 meme' #2`, want: `borik!meme '#1 meme' \#2`},
+		{name: "already escaped", content: `borik!magik \#2`, want: `borik!magik \#2`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := escapeMediaPicks(test.content); got != test.want {
+				t.Errorf("escapeMediaPicks(%q) = %q, want %q", test.content, got, test.want)
+			}
+		})
+	}
+}
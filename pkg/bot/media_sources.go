@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	messageLinkRegex = regexp.MustCompile(
+		`^https?://(?:(?:ptb|canary)\.)?discord(?:app)?\.com/channels/(\d+|@me)/(\d+)/(\d+)/?$`,
+	)
+	userMentionRegex = regexp.MustCompile(`^<@!?(\d+)>$`)
+	customEmojiRegex = regexp.MustCompile(`^<(a?):\w+:(\d+)>$`)
+)
+
+// directMessageGuild is used in place of a guild ID in links to messages in direct messages.
+const directMessageGuild = "@me"
+
+// linkedMessagePermissions are the permissions needed in a channel to use media from a message linked in it.
+const linkedMessagePermissions = discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory
+
+// stickerURLs returns the URL of each sticker in a message that can be read as media of the given kind.
+func stickerURLs(m *discordgo.Message, kind mediaType) []string {
+	var urls []string
+	for _, sticker := range m.StickerItems {
+		stickerURL, contentType, err := getStickerUrl(sticker)
+		if err == nil && kind.matchesContentType(contentType) {
+			urls = append(urls, stickerURL)
+		}
+	}
+	return urls
+}
+
+// resolveMediaSource resolves a reference to something on Discord that has media, such as a link to a message,
+// a user mention or a custom emoji, to the URL of that media.
+// The returned bool is false if arg isn't a reference of that kind.
+func (ctx *OperationContext) resolveMediaSource(arg string, kind mediaType) (string, bool, error) {
+	if match := messageLinkRegex.FindStringSubmatch(arg); match != nil {
+		if err := ctx.checkLinkedChannel(match[1], match[2]); err != nil {
+			return "", true, err
+		}
+		message, err := ctx.Session.ChannelMessage(match[2], match[3])
+		if err != nil {
+			return "", true, withUserMessage(
+				fmt.Errorf("error fetching linked message: %w", err),
+				"I couldn't fetch that message. I may not be able to see the channel it's in.",
+			)
+		}
+		mediaURL := mediaURLFromMessage(message, kind)
+		if mediaURL == "" {
+			return "", true, newUserError("I couldn't find any %s in that message.", kind.name)
+		}
+		return mediaURL, true, nil
+	}
+
+	if match := userMentionRegex.FindStringSubmatch(arg); match != nil {
+		mediaURL, err := avatarURL(ctx, &discordgo.User{ID: match[1]}, ctx.GetGuildID(), true)
+		return mediaURL, true, err
+	}
+
+	if match := customEmojiRegex.FindStringSubmatch(arg); match != nil {
+		return getEmojiUrl(&discordgo.Emoji{ID: match[2], Animated: match[1] == "a"}), true, nil
+	}
+
+	return "", false, nil
+}
+
+// checkLinkedChannel checks that the user running an operation can read the messages in a linked channel, so that
+// links can't be used to get at media the user couldn't see themselves. Links must be to messages in the same
+// server, or to the same direct message channel.
+func (ctx *OperationContext) checkLinkedChannel(guildID string, channelID string) error {
+	if guildID == directMessageGuild {
+		if ctx.GetGuildID() != "" || channelID != ctx.GetChannelID() {
+			return newUserError("I can only use links to direct messages in the conversation they're from.")
+		}
+		return nil
+	}
+	if guildID != ctx.GetGuildID() {
+		return newUserError("I can only use links to messages in this server.")
+	}
+
+	channel, err := ctx.Session.State.Channel(channelID)
+	if err != nil {
+		channel, err = ctx.Session.Channel(channelID)
+	}
+	if err != nil {
+		return withUserMessage(
+			fmt.Errorf("error fetching linked channel: %w", err),
+			"I couldn't fetch that message. I may not be able to see the channel it's in.",
+		)
+	}
+	if channel.GuildID != guildID {
+		return newUserError("I can only use links to messages in this server.")
+	}
+
+	permissions, err := ctx.Session.UserChannelPermissions(ctx.GetUserID(), channelID)
+	if err != nil {
+		return withUserMessage(
+			fmt.Errorf("error checking permissions in linked channel: %w", err),
+			"I couldn't check whether you can see that message.",
+		)
+	}
+	if permissions&linkedMessagePermissions != linkedMessagePermissions {
+		return newUserError("You don't have access to the messages in that channel.")
+	}
+	return nil
+}
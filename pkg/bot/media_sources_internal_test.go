@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// newTestMessageContext creates the context of a command run by a message in the given guild and channel.
+func newTestMessageContext(guildID string, channelID string) *OperationContext {
+	return &OperationContext{Message: &discordgo.MessageCreate{Message: &discordgo.Message{
+		GuildID:   guildID,
+		ChannelID: channelID,
+		Author:    &discordgo.User{ID: "user"},
+	}}}
+}
+
+func TestMessageLinkRegex(t *testing.T) {
+	match := messageLinkRegex.FindStringSubmatch("https://discord.com/channels/1/2/3")
+	if match == nil || match[1] != "1" || match[2] != "2" || match[3] != "3" {
+		t.Errorf("guild message link matched as %v", match)
+	}
+
+	match = messageLinkRegex.FindStringSubmatch("https://ptb.discordapp.com/channels/@me/2/3/")
+	if match == nil || match[1] != directMessageGuild || match[2] != "2" || match[3] != "3" {
+		t.Errorf("direct message link matched as %v", match)
+	}
+}
+
+// The cases below are all rejected or accepted before anything is fetched from Discord.
+func TestCheckLinkedChannel(t *testing.T) {
+	tests := []struct {
+		name      string
+		ctx       *OperationContext
+		guildID   string
+		channelID string
+		wantErr   bool
+	}{
+		{
+			name:      "other server",
+			ctx:       newTestMessageContext("guild", "channel"),
+			guildID:   "other guild",
+			channelID: "channel",
+			wantErr:   true,
+		},
+		{
+			name:      "direct message linked from a server",
+			ctx:       newTestMessageContext("guild", "channel"),
+			guildID:   directMessageGuild,
+			channelID: "channel",
+			wantErr:   true,
+		},
+		{
+			name:      "server message linked from a direct message",
+			ctx:       newTestMessageContext("", "dm"),
+			guildID:   "guild",
+			channelID: "channel",
+			wantErr:   true,
+		},
+		{
+			name:      "other direct message",
+			ctx:       newTestMessageContext("", "dm"),
+			guildID:   directMessageGuild,
+			channelID: "other dm",
+			wantErr:   true,
+		},
+		{
+			name:      "same direct message",
+			ctx:       newTestMessageContext("", "dm"),
+			guildID:   directMessageGuild,
+			channelID: "dm",
+			wantErr:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.ctx.checkLinkedChannel(test.guildID, test.channelID)
+			if (err != nil) != test.wantErr {
+				t.Errorf("checkLinkedChannel(%q, %q) = %v, want error: %v", test.guildID, test.channelID, err, test.wantErr)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
 	"math"
@@ -37,7 +38,7 @@ const (
 
 type MemeArgs struct {
 	Text     string `description:"Meme text. Use | to separate top and bottom text."`
-	ImageURL string `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL string `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 }
 
 func (args MemeArgs) GetImageURL() string {
@@ -205,7 +206,7 @@ func drawMemeText(wand *imagick.MagickWand, text string, bottom bool) error {
 }
 
 // Meme adds meme text to an image.
-func Meme(wand *imagick.MagickWand, args MemeArgs) ([]*imagick.MagickWand, error) {
+func Meme(_ context.Context, wand *imagick.MagickWand, args MemeArgs) ([]*imagick.MagickWand, error) {
 	topText, bottomText := parseMemeText(args.Text)
 
 	err := drawMemeText(wand, topText, false)
@@ -0,0 +1,265 @@
+package bot
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// MultiImageOperationArgs are the arguments of an operation that combines several images.
+type MultiImageOperationArgs interface {
+	// GetImageURLs returns one entry for each image the operation takes.
+	// Blank entries are located automatically.
+	GetImageURLs() []string
+}
+
+// MultiImageOperation combines the corresponding frames of several images into new frames.
+type MultiImageOperation[K MultiImageOperationArgs] func(
+	context.Context,
+	[]*imagick.MagickWand,
+	K,
+) ([]*imagick.MagickWand, error)
+
+// MakeMultiImageOpTextCommand creates a Parsley command handler for a given MultiImageOperation.
+func MakeMultiImageOpTextCommand[K MultiImageOperationArgs](
+	operation MultiImageOperation[K],
+) func(*discordgo.MessageCreate, K) {
+	return func(message *discordgo.MessageCreate, args K) {
+		PrepareAndInvokeMultiOperation(NewOperationContextFromMessage(Instance.session, message), args, operation)
+	}
+}
+
+func MakeMultiImageOpSlashCommand[K MultiImageOperationArgs](
+	operation MultiImageOperation[K],
+) func(*discordgo.Session, *discordgo.InteractionCreate, K) {
+	return func(session *discordgo.Session, interaction *discordgo.InteractionCreate, args K) {
+		PrepareAndInvokeMultiOperation(NewOperationContextFromInteraction(session, interaction), args, operation)
+	}
+}
+
+// splitImageURLs splits a comma-separated list of image URLs, padding it with blank entries up to count.
+func splitImageURLs(list string, count uint) []string {
+	var urls []string
+	for _, imageURL := range strings.Split(list, ",") {
+		if imageURL = strings.TrimSpace(imageURL); imageURL != "" {
+			urls = append(urls, imageURL)
+		}
+	}
+	for uint(len(urls)) < count {
+		urls = append(urls, "")
+	}
+	return urls
+}
+
+// findMediaURLs locates the images for a multi-image operation. Images given explicitly are used first,
+// followed by those attached to the message, the message it replies to, the last result in the channel,
+// and finally other recent messages in the channel.
+func (ctx *OperationContext) findMediaURLs(explicit []string, kind mediaType) ([]string, error) {
+	count := len(explicit)
+	var urls []string
+	add := func(candidates ...string) {
+		for _, candidate := range candidates {
+			if len(urls) < count && candidate != "" && !slices.Contains(urls, candidate) {
+				urls = append(urls, candidate)
+			}
+		}
+	}
+
+	for _, imageURL := range explicit {
+		if imageURL == "" {
+			continue
+		}
+		resolved, err := ctx.resolveMediaURL(imageURL, kind)
+		if err != nil {
+			return nil, err
+		}
+		add(resolved)
+	}
+
+	if ctx.Message != nil {
+		add(mediaURLsFromMessage(ctx.Message.Message, kind)...)
+		if ctx.Message.ReferencedMessage != nil {
+			add(mediaURLsFromMessage(ctx.Message.ReferencedMessage, kind)...)
+		}
+	}
+
+	if len(urls) < count {
+		if imageURL, ok := channelHistories.current(ctx.GetChannelID()); ok {
+			add(imageURL)
+		}
+	}
+
+	if len(urls) < count {
+		var beforeID string
+		if ctx.Message != nil {
+			beforeID = ctx.Message.ID
+		}
+		messages, err := ctx.Session.ChannelMessages(ctx.GetChannelID(), 20, beforeID, "", "")
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving message history: %w", err)
+		}
+		for _, message := range messages {
+			add(mediaURLsFromMessage(message, kind)...)
+		}
+	}
+
+	if len(urls) < count {
+		return nil, newUserError(
+			"I need %d images for this, but could only find %d. Attach them, reply to a message with one, or provide URLs.",
+			count,
+			len(urls),
+		)
+	}
+
+	return urls, nil
+}
+
+// PrepareAndInvokeMultiOperation automatically handles invoking a given MultiImageOperation and returning the
+// finished results.
+func PrepareAndInvokeMultiOperation[K MultiImageOperationArgs](
+	ctx *OperationContext,
+	args K,
+	operation MultiImageOperation[K],
+) {
+	if err := validateArgs(args); err != nil {
+		ctx.ReportError(err)
+		return
+	}
+
+	defer TypingIndicatorForContext(ctx)()
+
+	if err := ctx.DeferResponse(); err != nil {
+		log.Error().Err(err).Msg("Failed to defer response")
+		return
+	}
+
+	finish, err := ctx.StartJob()
+	if err != nil {
+		ctx.ReportError(err)
+		return
+	}
+	defer finish()
+
+	if err := invokeMultiOperation(ctx, args, operation); err != nil {
+		ctx.ReportError(err)
+	}
+}
+
+// invokeMultiOperation locates and downloads the images for an operation, runs the operation on their frames,
+// and uploads the result.
+func invokeMultiOperation[K MultiImageOperationArgs](
+	ctx *OperationContext,
+	args K,
+	operation MultiImageOperation[K],
+) error {
+	imageURLs, err := ctx.findMediaURLs(args.GetImageURLs(), visualMediaType)
+	if err != nil {
+		return withUserMessage(err, "I couldn't find the images to process.")
+	}
+
+	inputs := make([]*decodedImage, len(imageURLs))
+	inputHash := sha256.New()
+	for index, imageURL := range imageURLs {
+		srcBytes, err := DownloadImage(ctx.Context(), imageURL)
+		if err != nil {
+			return withUserMessage(err, fmt.Sprintf("I couldn't download image %d.", index+1))
+		}
+		inputSum := sha256.Sum256(srcBytes)
+		inputHash.Write(inputSum[:])
+
+		parsedURL, _ := url.Parse(imageURL)
+		if isVideo(srcBytes, path.Base(parsedURL.Path)) {
+			return newUserError("Image %d is a video, but this command can only combine images.", index+1)
+		}
+
+		inputs[index], err = decodeImage(srcBytes, path.Base(parsedURL.Path))
+		if err != nil {
+			return err
+		}
+	}
+
+	uploadLimit := ctx.GetUploadLimit()
+	outputFormat := animatedOutputFormat(ctx.GetCommandName())
+
+	var cacheKey string
+	if Instance.cache.enabled() && isCacheable(args) {
+		cacheKey, err = resultCacheKey(inputHash.Sum(nil), ctx.GetCommandName(), args, uploadLimit, outputFormat)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to compute cache key")
+		} else if result, ok := Instance.cache.Get(cacheKey); ok {
+			log.Debug().Str("key", cacheKey).Msg("Returning cached result")
+			return sendResult(ctx, imageURLs[0], nil, result)
+		}
+	}
+
+	// The input with the most frames sets the timing of the result, with shorter inputs looping alongside it.
+	timeline := inputs[0]
+	for _, input := range inputs[1:] {
+		if len(input.frames) > len(timeline.frames) {
+			timeline = input
+		}
+	}
+
+	// Frames are processed concurrently, so frames that are reused by looping inputs are copied up front.
+	groups := make([][]*imagick.MagickWand, len(timeline.frames))
+	for index := range groups {
+		for _, input := range inputs {
+			frame := input.frames[index%len(input.frames)]
+			if index >= len(input.frames) {
+				frame = frame.Clone()
+			}
+			groups[index] = append(groups[index], frame)
+		}
+	}
+
+	outputs, err := processFrames(
+		ctx.Context(),
+		timeline.frames,
+		frameWorkerCount(args),
+		func(opCtx context.Context, index int, _ *imagick.MagickWand) ([]*imagick.MagickWand, error) {
+			output, err := operation(opCtx, groups[index], args)
+			if err != nil {
+				return nil, err
+			}
+			if err := applyFrameTiming(output, timeline.timings[index], timeline.iterations); err != nil {
+				return nil, fmt.Errorf("error applying frame timing: %w", err)
+			}
+			return output, nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	result, err := encodeResultFrames(ctx.Context(), outputs, outputFormat, uploadLimit)
+	if err != nil {
+		return err
+	}
+
+	if cacheKey != "" {
+		Instance.cache.Put(cacheKey, result)
+	}
+
+	log.Debug().Msg("Images processed, uploading result")
+	return sendResult(ctx, imageURLs[0], nil, result)
+}
+
+// resizeToMatch returns a copy of a frame resized to exactly the given dimensions.
+func resizeToMatch(frame *imagick.MagickWand, width uint, height uint) (*imagick.MagickWand, error) {
+	resized := frame.Clone()
+	if resized.GetImageWidth() == width && resized.GetImageHeight() == height {
+		return resized, nil
+	}
+	if err := resized.ResizeImage(width, height, imagick.FILTER_LANCZOS); err != nil {
+		return nil, fmt.Errorf("error resizing image: %w", err)
+	}
+	return resized, nil
+}
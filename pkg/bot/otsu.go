@@ -1,13 +1,14 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
 
 type OtsuArgs struct {
-	ImageURL string `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL string `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 	Invert   bool   `default:"false" description:"Invert the colors."`
 }
 
@@ -16,7 +17,7 @@ func (args OtsuArgs) GetImageURL() string {
 }
 
 // Otsu turns the image black and white by applying an adaptive threshold using Otsu's method.
-func Otsu(wand *imagick.MagickWand, args OtsuArgs) ([]*imagick.MagickWand, error) {
+func Otsu(_ context.Context, wand *imagick.MagickWand, args OtsuArgs) ([]*imagick.MagickWand, error) {
 	numOfPixels := 0
 	histogram := map[int]int{}
 
@@ -0,0 +1,122 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/gographics/imagick.v3/imagick"
+
+	configPkg "github.com/fogo-sh/borik/pkg/config"
+)
+
+const (
+	outputFormatGIF  = configPkg.OutputFormatGIF
+	outputFormatMP4  = configPkg.OutputFormatMP4
+	outputFormatWebM = configPkg.OutputFormatWebM
+	outputFormatWebP = configPkg.OutputFormatWebP
+	outputFormatAPNG = configPkg.OutputFormatAPNG
+	outputFormatAuto = configPkg.OutputFormatAuto
+)
+
+// animatedOutputFormat returns the format that animated results of the named command are encoded to.
+func animatedOutputFormat(command string) string {
+	if format, ok := Instance.config.CommandOutputFormats[command]; ok {
+		return strings.ToLower(format)
+	}
+	return strings.ToLower(Instance.config.OutputFormat)
+}
+
+// encodeAnimatedResult encodes the frames of an animated result in the given output format.
+// In automatic mode every suitable format is tried, and whichever produces the smallest file is kept.
+func encodeAnimatedResult(
+	ctx context.Context,
+	frames []*imagick.MagickWand,
+	format string,
+	uploadLimit int64,
+	audio *audioSource,
+) (*cachedResult, error) {
+	candidates := []string{format}
+	if format == outputFormatAuto {
+		candidates = []string{outputFormatMP4, outputFormatWebM}
+		if audio == nil {
+			candidates = append([]string{outputFormatGIF, outputFormatWebP}, candidates...)
+		}
+	}
+
+	var best *cachedResult
+	for _, candidate := range candidates {
+		var result *cachedResult
+		var err error
+		if codec, ok := videoCodecs[candidate]; ok {
+			result, err = encodeFramesToVideo(ctx, frames, codec, audio, uploadLimit)
+		} else {
+			result, err = encodeImageResult(frames, imageOutputFormats[candidate], uploadLimit)
+		}
+
+		switch {
+		case errors.Is(err, errResultTooLarge):
+			log.Debug().Str("format", candidate).Msg("Result too large in this format")
+			continue
+		case err != nil && (len(candidates) == 1 || ctx.Err() != nil):
+			return nil, err
+		case err != nil:
+			log.Warn().Err(err).Str("format", candidate).Msg("Failed to encode result, trying other formats")
+			continue
+		}
+
+		log.Debug().Str("format", candidate).Int("size", len(result.Blob)).Msg("Encoded animated result")
+		if best == nil || len(result.Blob) < len(best.Blob) {
+			best = result
+		}
+	}
+
+	if best == nil {
+		return nil, &UserError{Message: uploadLimitError(uploadLimit), Err: errResultTooLarge}
+	}
+	return best, nil
+}
+
+// encodeImageResult combines result frames into a single image in the given format,
+// reducing it to fit within the upload limit if necessary.
+func encodeImageResult(
+	frames []*imagick.MagickWand,
+	format imageOutputFormat,
+	uploadLimit int64,
+) (*cachedResult, error) {
+	resultImage := imagick.NewMagickWand()
+	for index, frame := range frames {
+		log.Debug().Int("frame", index).Msg("Adding frame to result image")
+		err := resultImage.AddImage(frame)
+		if err != nil {
+			return nil, fmt.Errorf("error adding frame: %w", err)
+		}
+	}
+	resultImage.ResetIterator()
+
+	log.Debug().Msg("Setting image format")
+	err := resultImage.SetImageFormat(format.magickFormat)
+	if err != nil {
+		return nil, fmt.Errorf("error setting result format: %w", err)
+	}
+
+	log.Debug().Msg("Repaging image")
+	err = resultImage.ResetImagePage("0x0+0+0")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to repage image")
+	}
+
+	log.Debug().Msg("Encoding image")
+	imageBlob, reductions, err := fitToUploadLimit(resultImage, uploadLimit)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding image: %w", err)
+	}
+
+	return &cachedResult{
+		Format:  format.extension,
+		Content: uploadLimitMessage(uploadLimit, reductions),
+		Blob:    imageBlob,
+	}, nil
+}
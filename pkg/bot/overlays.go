@@ -35,8 +35,10 @@ func makeOverlayCommand(name, description string, op ImageOperation[OverlayImage
 	return Command{
 		name:         name,
 		description:  description,
+		category:     overlayCategory,
 		textHandler:  MakeImageOpTextCommand(op),
 		slashHandler: MakeImageOpSlashCommand(op),
+		operation:    makeRegisteredOperation(op),
 	}
 }
 
@@ -0,0 +1,217 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	metaTagRegex      = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	tagAttributeRegex = regexp.MustCompile(`(?s)([\w:-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+	giphyPageIDRegex  = regexp.MustCompile(`^/(?:gifs|stickers)/(?:[\w-]+-)?([A-Za-z0-9]+)/?$`)
+)
+
+// defaultPageMetaProperties are the metadata properties media is taken from on pages with no provider rules,
+// in order of preference. Images are preferred, as the video of an arbitrary page is often an embedded player.
+var defaultPageMetaProperties = []string{
+	"og:image:secure_url",
+	"og:image",
+	"twitter:image",
+	"twitter:image:src",
+	"og:video:secure_url",
+	"og:video",
+}
+
+// pageMediaProvider describes how to find the media shown by the pages of a particular site.
+type pageMediaProvider struct {
+	name    string
+	domains []string
+	// rewrite maps the URL of a page directly to the URL of its media, returning "" if it can't.
+	// When set, pages it handles are never fetched.
+	rewrite func(*url.URL) string
+	// properties lists the metadata properties to take media from, in order of preference.
+	properties []string
+}
+
+// defaultPageMediaProviders are the rules for known GIF and image hosting sites.
+var defaultPageMediaProviders = []pageMediaProvider{
+	{
+		name:    "tenor",
+		domains: []string{"tenor.com"},
+		// Tenor's og:image is the full GIF, while its og:video is a cut-down preview.
+		properties: []string{"og:image", "og:video:secure_url", "og:video"},
+	},
+	{
+		name:    "giphy",
+		domains: []string{"giphy.com"},
+		rewrite: func(pageURL *url.URL) string {
+			match := giphyPageIDRegex.FindStringSubmatch(pageURL.Path)
+			if match == nil {
+				return ""
+			}
+			return fmt.Sprintf("https://media.giphy.com/media/%s/giphy.gif", match[1])
+		},
+		properties: []string{"og:image", "og:video:secure_url", "og:video"},
+	},
+	{
+		name:    "imgur",
+		domains: []string{"imgur.com"},
+		// Animated posts are served as mp4 under og:video, with og:image only holding a still.
+		properties: []string{"og:video:secure_url", "og:video", "og:image", "twitter:image"},
+	},
+}
+
+// pageFetcher requests URLs on behalf of a PageResolver.
+type pageFetcher interface {
+	Get(ctx context.Context, rawURL string) (*http.Response, error)
+}
+
+// PageResolver follows links to web pages, such as GIF site views and articles, through to the media they show.
+type PageResolver struct {
+	fetcher     pageFetcher
+	maxPageSize int64
+	providers   []pageMediaProvider
+}
+
+// NewPageResolver constructs a new PageResolver, fetching pages and their media with the given fetcher.
+func NewPageResolver(fetcher pageFetcher, maxPageSize int64) *PageResolver {
+	return &PageResolver{
+		fetcher:     fetcher,
+		maxPageSize: maxPageSize,
+		providers:   defaultPageMediaProviders,
+	}
+}
+
+// provider returns the rules for the site a URL belongs to, if it's a known one.
+func (r *PageResolver) provider(target *url.URL) (pageMediaProvider, bool) {
+	host := strings.ToLower(strings.TrimSuffix(target.Hostname(), "."))
+	for _, provider := range r.providers {
+		if domainMatches(host, provider.domains) {
+			return provider, true
+		}
+	}
+	return pageMediaProvider{}, false
+}
+
+// isKnownPage reports whether a URL is a page on one of the known GIF and image hosting sites.
+func (r *PageResolver) isKnownPage(rawURL string) bool {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	_, ok := r.provider(target)
+	return ok && !hasMediaExtension(target.Path)
+}
+
+// Open requests a URL, following it through to the media it shows if it's a web page.
+// The caller is responsible for closing the response body.
+func (r *PageResolver) Open(ctx context.Context, rawURL string) (*http.Response, error) {
+	if target, err := url.Parse(rawURL); err == nil {
+		if provider, ok := r.provider(target); ok && provider.rewrite != nil {
+			if mediaURL := provider.rewrite(target); mediaURL != "" {
+				log.Debug().Str("provider", provider.name).Str("page", rawURL).Msg("Rewrote page URL to media")
+				rawURL = mediaURL
+			}
+		}
+	}
+
+	resp, err := r.fetcher.Get(ctx, rawURL)
+	if err != nil || !isHTMLResponse(resp) {
+		return resp, err
+	}
+
+	mediaURL, err := r.mediaFromPage(resp)
+	closeBody(resp.Body, "Error closing page response body")
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Str("page", rawURL).Str("media", mediaURL).Msg("Resolved page to media")
+
+	resp, err = r.fetcher.Get(ctx, mediaURL)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting media from page: %w", err)
+	}
+	if isHTMLResponse(resp) {
+		closeBody(resp.Body, "Error closing page response body")
+		return nil, newUserError("That page doesn't link directly to any media I can use.")
+	}
+	return resp, nil
+}
+
+// mediaFromPage extracts the URL of the media shown by a page from its metadata.
+func (r *PageResolver) mediaFromPage(resp *http.Response) (string, error) {
+	body := io.Reader(resp.Body)
+	if r.maxPageSize > 0 {
+		body = io.LimitReader(resp.Body, r.maxPageSize)
+	}
+	page, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("error reading page: %w", err)
+	}
+
+	properties := defaultPageMetaProperties
+	if provider, ok := r.provider(resp.Request.URL); ok {
+		properties = provider.properties
+	}
+
+	meta := pageMetadata(string(page))
+	for _, property := range properties {
+		content, ok := meta[property]
+		if !ok {
+			continue
+		}
+		mediaURL, err := resp.Request.URL.Parse(content)
+		if err != nil || (mediaURL.Scheme != "http" && mediaURL.Scheme != "https") {
+			continue
+		}
+		return mediaURL.String(), nil
+	}
+
+	return "", newUserError("I couldn't find any media on that page.")
+}
+
+// pageMetadata collects the content of each meta tag in a page, keyed by its property or name.
+// Only the first value of each property is kept.
+func pageMetadata(page string) map[string]string {
+	meta := map[string]string{}
+	for _, tag := range metaTagRegex.FindAllString(page, -1) {
+		attributes := map[string]string{}
+		for _, match := range tagAttributeRegex.FindAllStringSubmatch(tag, -1) {
+			attributes[strings.ToLower(match[1])] = html.UnescapeString(strings.Trim(match[2], `"'`))
+		}
+
+		key := attributes["property"]
+		if key == "" {
+			key = attributes["name"]
+		}
+		content, ok := attributes["content"]
+		if key == "" || !ok || content == "" {
+			continue
+		}
+		if _, exists := meta[strings.ToLower(key)]; !exists {
+			meta[strings.ToLower(key)] = strings.TrimSpace(content)
+		}
+	}
+	return meta
+}
+
+// isHTMLResponse reports whether a response is a web page rather than media.
+func isHTMLResponse(resp *http.Response) bool {
+	contentType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && (contentType == "text/html" || contentType == "application/xhtml+xml")
+}
+
+// hasMediaExtension reports whether a path has the extension of an image or video.
+func hasMediaExtension(urlPath string) bool {
+	return visualMediaType.matchesContentType(mime.TypeByExtension(strings.ToLower(path.Ext(urlPath))))
+}
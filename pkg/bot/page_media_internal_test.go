@@ -0,0 +1,389 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testPage is a response served by a testSites server.
+type testPage struct {
+	contentType string
+	body        string
+}
+
+// testSites stands in for the sites pages and media are fetched from, serving responses for the URLs registered
+// with it whatever their host.
+type testSites struct {
+	server *httptest.Server
+	pages  map[string]testPage
+
+	mu      sync.Mutex
+	fetched []string
+}
+
+// newTestSites starts a server serving the given responses, keyed by URL.
+func newTestSites(t *testing.T, pages map[string]testPage) *testSites {
+	t.Helper()
+	sites := &testSites{pages: pages}
+	sites.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := sites.pages[r.Header.Get("X-Test-URL")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", page.contentType)
+		_, _ = fmt.Fprint(w, page.body)
+	}))
+	t.Cleanup(sites.server.Close)
+	return sites
+}
+
+// Get requests a URL from the test server, reporting the response as having come from the URL itself so that
+// providers and relative links are resolved as they would be for the real site.
+func (s *testSites) Get(ctx context.Context, rawURL string) (*http.Response, error) {
+	s.mu.Lock()
+	s.fetched = append(s.fetched, rawURL)
+	s.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.server.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Test-URL", rawURL)
+	resp, err := s.server.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Request, err = http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		closeBody(resp.Body, "Error closing test response body")
+		return nil, err
+	}
+	return resp, nil
+}
+
+// fetchedURLs returns the URLs requested so far, in order.
+func (s *testSites) fetchedURLs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.fetched...)
+}
+
+// htmlPage builds a page with the given meta tags in its head.
+func htmlPage(metaTags ...string) testPage {
+	return testPage{
+		contentType: "text/html; charset=utf-8",
+		body:        "<!DOCTYPE html><html><head>" + strings.Join(metaTags, "\n") + "</head><body></body></html>",
+	}
+}
+
+// metaProperty builds a meta tag giving a value for a property.
+func metaProperty(property string, content string) string {
+	return fmt.Sprintf(`<meta property="%s" content="%s">`, property, content)
+}
+
+// mediaFile is a media response, with its URL as its body so that tests can tell which was served.
+func mediaFile(contentType string, mediaURL string) testPage {
+	return testPage{contentType: contentType, body: mediaURL}
+}
+
+// openURL opens a URL with a resolver, returning the URL of the response it was resolved to.
+func openURL(t *testing.T, resolver *PageResolver, rawURL string) (string, error) {
+	t.Helper()
+	resp, err := resolver.Open(context.Background(), rawURL)
+	if err != nil {
+		return "", err
+	}
+	defer closeBody(resp.Body, "Error closing test response body")
+	return resp.Request.URL.String(), nil
+}
+
+func TestPageResolverPropertyPreference(t *testing.T) {
+	tests := []struct {
+		name    string
+		page    string
+		want    string
+		content map[string]testPage
+	}{
+		{
+			name: "tenor prefers the full gif to its video preview",
+			page: "https://tenor.com/view/cat-gif-123",
+			want: "https://media.tenor.com/cat.gif",
+			content: map[string]testPage{
+				"https://tenor.com/view/cat-gif-123": htmlPage(
+					metaProperty("og:video", "https://media.tenor.com/cat.mp4"),
+					metaProperty("og:image", "https://media.tenor.com/cat.gif"),
+				),
+				"https://media.tenor.com/cat.gif": mediaFile("image/gif", "https://media.tenor.com/cat.gif"),
+			},
+		},
+		{
+			name: "imgur prefers the video to its still",
+			page: "https://imgur.com/abc123",
+			want: "https://i.imgur.com/abc123.mp4",
+			content: map[string]testPage{
+				"https://imgur.com/abc123": htmlPage(
+					metaProperty("og:image", "https://i.imgur.com/abc123h.jpg"),
+					metaProperty("og:video", "https://i.imgur.com/abc123.mp4"),
+				),
+				"https://i.imgur.com/abc123.mp4": mediaFile("video/mp4", "https://i.imgur.com/abc123.mp4"),
+			},
+		},
+		{
+			name: "other pages prefer images to videos",
+			page: "https://example.com/article",
+			want: "https://example.com/cover.png",
+			content: map[string]testPage{
+				"https://example.com/article": htmlPage(
+					metaProperty("og:video", "https://example.com/player.mp4"),
+					`<meta name="twitter:image" content="https://example.com/card.png">`,
+					metaProperty("og:image", "https://example.com/cover.png"),
+				),
+				"https://example.com/cover.png": mediaFile("image/png", "https://example.com/cover.png"),
+			},
+		},
+		{
+			name: "secure URLs are preferred",
+			page: "https://example.com/article",
+			want: "https://example.com/secure.png",
+			content: map[string]testPage{
+				"https://example.com/article": htmlPage(
+					metaProperty("og:image", "http://example.com/cover.png"),
+					metaProperty("og:image:secure_url", "https://example.com/secure.png"),
+				),
+				"https://example.com/secure.png": mediaFile("image/png", "https://example.com/secure.png"),
+			},
+		},
+		{
+			name: "falls back to the video of other pages",
+			page: "https://example.com/clip",
+			want: "https://example.com/clip.mp4",
+			content: map[string]testPage{
+				"https://example.com/clip":     htmlPage(metaProperty("og:video", "https://example.com/clip.mp4")),
+				"https://example.com/clip.mp4": mediaFile("video/mp4", "https://example.com/clip.mp4"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sites := newTestSites(t, test.content)
+			got, err := openURL(t, NewPageResolver(sites, 0), test.page)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("resolved to %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPageResolverRewritesGiphyPages(t *testing.T) {
+	const mediaURL = "https://media.giphy.com/media/xT9IgG50Fb7Mi0prBC/giphy.gif"
+	sites := newTestSites(t, map[string]testPage{mediaURL: mediaFile("image/gif", mediaURL)})
+
+	got, err := openURL(t, NewPageResolver(sites, 0), "https://giphy.com/gifs/cat-funny-xT9IgG50Fb7Mi0prBC")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != mediaURL {
+		t.Errorf("resolved to %s, want %s", got, mediaURL)
+	}
+	if fetched := sites.fetchedURLs(); len(fetched) != 1 {
+		t.Errorf("fetched %v, want only the media", fetched)
+	}
+}
+
+func TestPageResolverRelativeURLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "root relative", content: "/media/cover.png", want: "https://example.com/media/cover.png"},
+		{name: "path relative", content: "cover.png", want: "https://example.com/posts/cover.png"},
+		{name: "scheme relative", content: "//cdn.example.com/cover.png", want: "https://cdn.example.com/cover.png"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sites := newTestSites(t, map[string]testPage{
+				"https://example.com/posts/1": htmlPage(metaProperty("og:image", test.content)),
+				test.want:                     mediaFile("image/png", test.want),
+			})
+
+			got, err := openURL(t, NewPageResolver(sites, 0), "https://example.com/posts/1")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("resolved to %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPageResolverSkipsNonHTTPURLs(t *testing.T) {
+	sites := newTestSites(t, map[string]testPage{
+		"https://example.com/article": htmlPage(
+			metaProperty("og:image", "data:image/png;base64,AAAA"),
+			metaProperty("twitter:image", "https://example.com/card.png"),
+		),
+		"https://example.com/card.png": mediaFile("image/png", "https://example.com/card.png"),
+	})
+
+	got, err := openURL(t, NewPageResolver(sites, 0), "https://example.com/article")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "https://example.com/card.png" {
+		t.Errorf("resolved to %s, want the first http URL", got)
+	}
+}
+
+func TestPageResolverMaxPageSize(t *testing.T) {
+	page := htmlPage(
+		"<!-- "+strings.Repeat("padding ", 100)+"-->",
+		metaProperty("og:image", "https://example.com/cover.png"),
+	)
+	sites := newTestSites(t, map[string]testPage{
+		"https://example.com/article":   page,
+		"https://example.com/cover.png": mediaFile("image/png", "https://example.com/cover.png"),
+	})
+
+	_, err := openURL(t, NewPageResolver(sites, 200), "https://example.com/article")
+	var userErr *UserError
+	if !errors.As(err, &userErr) {
+		t.Errorf("got error %v for metadata past the size limit, want a UserError", err)
+	}
+
+	if _, err := openURL(t, NewPageResolver(sites, int64(len(page.body))), "https://example.com/article"); err != nil {
+		t.Errorf("unexpected error for a page within the size limit: %v", err)
+	}
+}
+
+func TestPageResolverHTMLMedia(t *testing.T) {
+	sites := newTestSites(t, map[string]testPage{
+		"https://example.com/article": htmlPage(metaProperty("og:image", "https://example.com/other-article")),
+		"https://example.com/other-article": htmlPage(
+			metaProperty("og:image", "https://example.com/cover.png"),
+		),
+	})
+
+	_, err := openURL(t, NewPageResolver(sites, 0), "https://example.com/article")
+	var userErr *UserError
+	if !errors.As(err, &userErr) {
+		t.Errorf("got error %v for a page linking to another page, want a UserError", err)
+	}
+	if fetched := sites.fetchedURLs(); len(fetched) != 2 {
+		t.Errorf("fetched %v, want the page and what it linked to only", fetched)
+	}
+}
+
+func TestPageResolverPassesThroughMedia(t *testing.T) {
+	const mediaURL = "https://example.com/cat.gif"
+	sites := newTestSites(t, map[string]testPage{mediaURL: mediaFile("image/gif", mediaURL)})
+
+	got, err := openURL(t, NewPageResolver(sites, 0), mediaURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != mediaURL {
+		t.Errorf("resolved to %s, want %s", got, mediaURL)
+	}
+	if fetched := sites.fetchedURLs(); len(fetched) != 1 {
+		t.Errorf("fetched %v, want only the media", fetched)
+	}
+}
+
+func TestPageMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want map[string]string
+	}{
+		{
+			name: "property",
+			page: `<meta property="og:image" content="https://example.com/a.png">`,
+			want: map[string]string{"og:image": "https://example.com/a.png"},
+		},
+		{
+			name: "name",
+			page: `<meta name="twitter:image" content="https://example.com/a.png" />`,
+			want: map[string]string{"twitter:image": "https://example.com/a.png"},
+		},
+		{
+			name: "content before property",
+			page: `<meta content="https://example.com/a.png" property="og:image">`,
+			want: map[string]string{"og:image": "https://example.com/a.png"},
+		},
+		{
+			name: "single quoted and unquoted attributes",
+			page: `<meta property='og:image' content='https://example.com/a.png'><meta property=og:video content=b.mp4>`,
+			want: map[string]string{"og:image": "https://example.com/a.png", "og:video": "b.mp4"},
+		},
+		{
+			name: "case and spacing",
+			page: "<META\n  Property=\"OG:Image\"\n  CONTENT=\" https://example.com/a.png \">",
+			want: map[string]string{"og:image": "https://example.com/a.png"},
+		},
+		{
+			name: "entities",
+			page: `<meta property="og:image" content="https://example.com/a.png?w=1&amp;h=2">`,
+			want: map[string]string{"og:image": "https://example.com/a.png?w=1&h=2"},
+		},
+		{
+			name: "first value kept",
+			page: `<meta property="og:image" content="first.png"><meta property="og:image" content="second.png">`,
+			want: map[string]string{"og:image": "first.png"},
+		},
+		{
+			name: "empty and missing content skipped",
+			page: `<meta property="og:image" content=""><meta property="og:video"><meta charset="utf-8">`,
+			want: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := pageMetadata(test.page)
+			if len(got) != len(test.want) {
+				t.Errorf("pageMetadata = %v, want %v", got, test.want)
+				return
+			}
+			for key, value := range test.want {
+				if got[key] != value {
+					t.Errorf("pageMetadata[%q] = %q, want %q", key, got[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestIsKnownPage(t *testing.T) {
+	resolver := NewPageResolver(nil, 0)
+	tests := []struct {
+		rawURL string
+		want   bool
+	}{
+		{rawURL: "https://tenor.com/view/cat-gif-123", want: true},
+		{rawURL: "https://www.imgur.com/abc123", want: true},
+		{rawURL: "https://i.imgur.com/abc123.png", want: false},
+		{rawURL: "https://example.com/article", want: false},
+		{rawURL: "https://nottenor.com/view/cat", want: false},
+	}
+
+	for _, test := range tests {
+		if got := resolver.isKnownPage(test.rawURL); got != test.want {
+			t.Errorf("isKnownPage(%s) = %v, want %v", test.rawURL, got, test.want)
+		}
+	}
+}
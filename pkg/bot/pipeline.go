@@ -0,0 +1,369 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/google/shlex"
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// registeredOperation is a type-erased ImageOperation, allowing operations to be looked up and invoked by name.
+type registeredOperation struct {
+	argsType reflect.Type
+	run      func(context.Context, *imagick.MagickWand, ImageOperationArgs) ([]*imagick.MagickWand, error)
+}
+
+// makeRegisteredOperation wraps an ImageOperation so that it can be stored in the operation registry.
+func makeRegisteredOperation[K ImageOperationArgs](operation ImageOperation[K]) *registeredOperation {
+	return &registeredOperation{
+		argsType: reflect.TypeFor[K](),
+		run: func(ctx context.Context, wand *imagick.MagickWand, args ImageOperationArgs) ([]*imagick.MagickWand, error) {
+			return operation(ctx, wand, args.(K))
+		},
+	}
+}
+
+// parseArgs parses a list of text arguments into an instance of the operation's argument struct.
+func (op *registeredOperation) parseArgs(arguments []string) (ImageOperationArgs, error) {
+	value, err := parseArgsStruct(op.argsType, arguments)
+	if err != nil {
+		return nil, err
+	}
+	return value.Interface().(ImageOperationArgs), nil
+}
+
+// operationRegistry contains every enabled ImageOperation, keyed by command name and alias.
+var operationRegistry = map[string]*registeredOperation{}
+
+// keywordArgRegex matches the key of a key=value argument. Other arguments containing =, such as URLs with query
+// strings, are positional.
+var keywordArgRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var smartQuoteReplacer = strings.NewReplacer(
+	"‘", "'",
+	"’", "'",
+	"‚", "'",
+	"‛", "'",
+	"“", "\"",
+	"”", "\"",
+	"„", "\"",
+	"‟", "\"",
+)
+
+// parseArgsStruct populates a new instance of argsType from a list of text arguments.
+// Arguments are matched positionally, or by their field name, ignoring case, using key=value syntax,
+// falling back to the default tag of each field, mirroring the behaviour of text commands.
+// A key=value argument naming no field is an error, rather than being taken as a positional argument.
+func parseArgsStruct(argsType reflect.Type, arguments []string) (reflect.Value, error) {
+	argsValue := reflect.New(argsType).Elem()
+
+	kwargs := map[string]string{}
+	var positional []string
+
+	for _, argument := range arguments {
+		key, value, found := strings.Cut(argument, "=")
+		if found && keywordArgRegex.MatchString(key) {
+			field, ok := findArgsField(argsType, key)
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("unknown argument %s", key)
+			}
+			kwargs[field.Name] = value
+			continue
+		}
+		if len(kwargs) > 0 {
+			return reflect.Value{}, fmt.Errorf("keyword arguments must be provided as the last arguments")
+		}
+		positional = append(positional, argument)
+	}
+
+	if len(positional) > argsType.NumField() {
+		return reflect.Value{}, fmt.Errorf("too many arguments provided (expected at most %d)", argsType.NumField())
+	}
+
+	for index := 0; index < argsType.NumField(); index++ {
+		fieldType := argsType.Field(index)
+
+		value, found := kwargs[fieldType.Name]
+		if !found {
+			if index < len(positional) {
+				value = positional[index]
+			} else {
+				defaultValue, hasDefault := fieldType.Tag.Lookup("default")
+				if !hasDefault {
+					return reflect.Value{}, fmt.Errorf("missing required argument %s", fieldType.Name)
+				}
+				value = defaultValue
+			}
+		}
+
+		if err := setArgsField(argsValue.Field(index), value); err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid value for argument %s: %w", fieldType.Name, err)
+		}
+	}
+
+	if err := validateArgs(argsValue.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+
+	return argsValue, nil
+}
+
+func findArgsField(argsType reflect.Type, name string) (reflect.StructField, bool) {
+	for index := 0; index < argsType.NumField(); index++ {
+		field := argsType.Field(index)
+		if strings.EqualFold(field.Name, name) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func setArgsField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	case reflect.String:
+		field.SetString(value)
+	default:
+		return fmt.Errorf("unsupported argument type %s", field.Type())
+	}
+	return nil
+}
+
+// pipelineStage is a single operation within a pipeline, along with its parsed arguments.
+type pipelineStage struct {
+	name      string
+	operation *registeredOperation
+	args      ImageOperationArgs
+}
+
+// pipeline is a sequence of operations, each of which is fed the frames produced by the previous stage.
+type pipeline []pipelineStage
+
+// parsePipeline parses a tokenized pipeline definition, with stages separated by a standalone "|".
+func parsePipeline(tokens []string) (pipeline, error) {
+	var stageTokens [][]string
+	current := []string{}
+	for _, token := range tokens {
+		if token == "|" {
+			stageTokens = append(stageTokens, current)
+			current = []string{}
+			continue
+		}
+		current = append(current, token)
+	}
+	stageTokens = append(stageTokens, current)
+
+	stages := make(pipeline, 0, len(stageTokens))
+	for index, tokens := range stageTokens {
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("stage %d of the pipeline is empty", index+1)
+		}
+
+		name := strings.ToLower(tokens[0])
+		operation, ok := operationRegistry[name]
+		if !ok {
+			return nil, fmt.Errorf("stage %d: unknown operation %q", index+1, tokens[0])
+		}
+
+		args, err := operation.parseArgs(tokens[1:])
+		if err != nil {
+			return nil, fmt.Errorf("stage %d (%s): %w", index+1, name, err)
+		}
+
+		stages = append(stages, pipelineStage{name: name, operation: operation, args: args})
+	}
+
+	return stages, nil
+}
+
+// parsePipelineString tokenizes and parses a pipeline definition.
+func parsePipelineString(definition string) (pipeline, error) {
+	tokens, err := shlex.Split(smartQuoteReplacer.Replace(definition))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing pipeline: %w", err)
+	}
+	return parsePipeline(tokens)
+}
+
+// imageURL returns the image URL provided to the first stage of the pipeline, if any.
+func (p pipeline) imageURL() string {
+	if len(p) == 0 {
+		return ""
+	}
+	return p[0].args.GetImageURL()
+}
+
+// apply runs a frame through each stage of the pipeline in turn.
+func (p pipeline) apply(ctx context.Context, wand *imagick.MagickWand) ([]*imagick.MagickWand, error) {
+	frames := []*imagick.MagickWand{wand}
+
+	for _, stage := range p {
+		var stageOutput []*imagick.MagickWand
+		for _, frame := range frames {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			output, err := stage.operation.run(ctx, frame, stage.args)
+			if err != nil {
+				return nil, fmt.Errorf("error running %s: %w", stage.name, err)
+			}
+			stageOutput = append(stageOutput, output...)
+		}
+		frames = stageOutput
+	}
+
+	return frames, nil
+}
+
+type ChainArgs struct {
+	Pipeline string `description:"Operations to run, separated by |. For example: magik Scale=2 | deepfry | meme \"TOP|BOTTOM\""`
+	ImageURL string `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
+}
+
+// ChainTextArgs describes the arguments of the text variant of chain for its registration and help.
+// ChainTextCommand ignores the value it's given, as the pipeline spans many arguments, and re-parses the message
+// instead. The image URL can be given as the first argument to the first stage.
+type ChainTextArgs struct {
+	Pipeline string `description:"Operations to run, separated by |. For example: magik Scale=2 | deepfry | meme \"TOP|BOTTOM\""`
+}
+
+func (args ChainArgs) GetImageURL() string {
+	return args.ImageURL
+}
+
+// pipelineArgs pairs the arguments of a chain command with its parsed pipeline.
+type pipelineArgs struct {
+	ChainArgs
+	stages pipeline
+}
+
+// ProcessFramesSequentially reports whether any stage of the pipeline requires its frames to be processed in sequence.
+func (args pipelineArgs) ProcessFramesSequentially() bool {
+	for _, stage := range args.stages {
+		if sequential, ok := stage.args.(SequentialOperationArgs); ok && sequential.ProcessFramesSequentially() {
+			return true
+		}
+	}
+	return false
+}
+
+func (args pipelineArgs) Cacheable() bool {
+	for _, stage := range args.stages {
+		if !isCacheable(stage.args) {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalJSON encodes the stages of the pipeline, so that the cache can tell pipelines apart.
+func (args pipelineArgs) MarshalJSON() ([]byte, error) {
+	type stageJSON struct {
+		Operation string
+		Args      json.RawMessage
+	}
+
+	stages := make([]stageJSON, len(args.stages))
+	for index, stage := range args.stages {
+		normalisedArgs, err := normaliseArgs(stage.args)
+		if err != nil {
+			return nil, err
+		}
+		stages[index] = stageJSON{Operation: stage.name, Args: normalisedArgs}
+	}
+
+	return json.Marshal(stages)
+}
+
+func invokePipeline(ctx *OperationContext, args ChainArgs, stages pipeline) {
+	if args.ImageURL == "" {
+		args.ImageURL = stages.imageURL()
+	}
+
+	PrepareAndInvokeOperation(
+		ctx,
+		pipelineArgs{args, stages},
+		func(opCtx context.Context, wand *imagick.MagickWand, args pipelineArgs) ([]*imagick.MagickWand, error) {
+			return args.stages.apply(opCtx, wand)
+		},
+	)
+}
+
+func sendPipelineError(ctx *OperationContext, err error) {
+	ctx.ReportError(&UserError{Message: fmt.Sprintf("Invalid pipeline: `%s`", err.Error()), Err: err})
+}
+
+// ChainTextCommand runs an image through a pipeline of operations from a text command.
+// The full message content is re-parsed, as text commands only receive the first token of the pipeline.
+func ChainTextCommand(message *discordgo.MessageCreate, _ ChainTextArgs) {
+	ctx := NewOperationContextFromMessage(Instance.session, message)
+
+	content := message.Content
+	for _, prefix := range Instance.config.Prefixes {
+		if strings.HasPrefix(content, prefix) {
+			content = strings.TrimPrefix(content, prefix)
+			break
+		}
+	}
+
+	tokens, err := shlex.Split(smartQuoteReplacer.Replace(content))
+	if err != nil {
+		sendPipelineError(ctx, err)
+		return
+	}
+	if len(tokens) > 0 {
+		tokens = tokens[1:]
+	}
+
+	stages, err := parsePipeline(tokens)
+	if err != nil {
+		sendPipelineError(ctx, err)
+		return
+	}
+
+	invokePipeline(ctx, ChainArgs{}, stages)
+}
+
+// ChainSlashCommand runs an image through a pipeline of operations from a slash command.
+func ChainSlashCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate, args ChainArgs) {
+	ctx := NewOperationContextFromInteraction(session, interaction)
+
+	stages, err := parsePipelineString(args.Pipeline)
+	if err != nil {
+		sendPipelineError(ctx, err)
+		return
+	}
+
+	invokePipeline(ctx, args, stages)
+}
@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"maps"
+	"reflect"
+	"testing"
+)
+
+// registerTestOperations adds the operations used by pipeline tests to the registry for the duration of a test.
+func registerTestOperations(t *testing.T) {
+	t.Helper()
+
+	original := maps.Clone(operationRegistry)
+	t.Cleanup(func() { operationRegistry = original })
+
+	operationRegistry["magik"] = makeRegisteredOperation(Magik)
+	operationRegistry["meme"] = makeRegisteredOperation(Meme)
+}
+
+func TestParseArgsStruct(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments []string
+		want      MagikArgs
+		wantErr   bool
+	}{
+		{
+			name:      "defaults",
+			arguments: nil,
+			want:      MagikArgs{ImageURL: "", Scale: 1, WidthMultiplier: 0.5, HeightMultiplier: 0.5},
+		},
+		{
+			name:      "positional",
+			arguments: []string{"https://example.com/a.png", "3"},
+			want:      MagikArgs{ImageURL: "https://example.com/a.png", Scale: 3, WidthMultiplier: 0.5, HeightMultiplier: 0.5},
+		},
+		{
+			name:      "keyword",
+			arguments: []string{"Scale=2", "HeightMultiplier=0.25"},
+			want:      MagikArgs{ImageURL: "", Scale: 2, WidthMultiplier: 0.5, HeightMultiplier: 0.25},
+		},
+		{
+			name:      "keyword after positional",
+			arguments: []string{"https://example.com/a.png", "Scale=4"},
+			want:      MagikArgs{ImageURL: "https://example.com/a.png", Scale: 4, WidthMultiplier: 0.5, HeightMultiplier: 0.5},
+		},
+		{
+			name:      "keyword names ignore case",
+			arguments: []string{"scale=2", "heightmultiplier=0.25"},
+			want:      MagikArgs{ImageURL: "", Scale: 2, WidthMultiplier: 0.5, HeightMultiplier: 0.25},
+		},
+		{
+			name:      "URL with a query string",
+			arguments: []string{"https://example.com/a.png?size=2", "Scale=3"},
+			want: MagikArgs{
+				ImageURL:         "https://example.com/a.png?size=2",
+				Scale:            3,
+				WidthMultiplier:  0.5,
+				HeightMultiplier: 0.5,
+			},
+		},
+		{
+			name:      "unknown keyword",
+			arguments: []string{"Sclae=2"},
+			wantErr:   true,
+		},
+		{
+			name:      "positional after keyword",
+			arguments: []string{"Scale=2", "https://example.com/a.png"},
+			wantErr:   true,
+		},
+		{
+			name:      "too many arguments",
+			arguments: []string{"a", "1", "1", "1", "1"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid value",
+			arguments: []string{"Scale=big"},
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := parseArgsStruct(reflect.TypeFor[MagikArgs](), test.arguments)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", value.Interface())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := value.Interface().(MagikArgs); got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseArgsStructMissingRequired(t *testing.T) {
+	if _, err := parseArgsStruct(reflect.TypeFor[MemeArgs](), nil); err == nil {
+		t.Fatal("expected an error for the missing Text argument")
+	}
+}
+
+func TestParsePipelineString(t *testing.T) {
+	registerTestOperations(t)
+
+	stages, err := parsePipelineString(`magik https://example.com/a.png Scale=2 | meme "TOP|BOTTOM"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stages) != 2 {
+		t.Fatalf("got %d stages, want 2", len(stages))
+	}
+
+	if stages[0].name != "magik" || stages[0].args.(MagikArgs).Scale != 2 {
+		t.Errorf("unexpected first stage %s %+v", stages[0].name, stages[0].args)
+	}
+	if stages[1].name != "meme" || stages[1].args.(MemeArgs).Text != "TOP|BOTTOM" {
+		t.Errorf("unexpected second stage %s %+v", stages[1].name, stages[1].args)
+	}
+	if got := stages.imageURL(); got != "https://example.com/a.png" {
+		t.Errorf("got image URL %q, want the first stage's", got)
+	}
+}
+
+func TestParsePipelineStringLowercaseKeywords(t *testing.T) {
+	registerTestOperations(t)
+
+	stages, err := parsePipelineString("magik scale=2 | meme hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if args := stages[0].args.(MagikArgs); args.Scale != 2 || args.ImageURL != "" {
+		t.Errorf("got %+v, want Scale 2 and no image URL", args)
+	}
+}
+
+func TestParsePipelineStringSmartQuotes(t *testing.T) {
+	registerTestOperations(t)
+
+	stages, err := parsePipelineString("meme “TOP|BOTTOM”")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := stages[0].args.(MemeArgs).Text; got != "TOP|BOTTOM" {
+		t.Errorf("got text %q, want TOP|BOTTOM", got)
+	}
+}
+
+func TestParsePipelineStringErrors(t *testing.T) {
+	registerTestOperations(t)
+
+	tests := []struct {
+		name       string
+		definition string
+	}{
+		{name: "empty stage", definition: "magik | | meme hi"},
+		{name: "trailing separator", definition: "magik |"},
+		{name: "unknown operation", definition: "magik | nonexistent"},
+		{name: "missing required argument", definition: "magik | meme"},
+		{name: "unterminated quote", definition: `meme "TOP`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parsePipelineString(test.definition); err == nil {
+				t.Errorf("expected an error parsing %q", test.definition)
+			}
+		})
+	}
+}
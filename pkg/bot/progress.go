@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// progressPreviewSize is the maximum width and height of the preview attached to progress updates.
+const progressPreviewSize = 256
+
+type progressKey struct{}
+
+// ProgressReporter keeps the requester informed of the progress of a long-running job, by editing a status message
+// or the deferred interaction response. Updates are rate-limited, and are only shown once the job has been running
+// for a while.
+type ProgressReporter struct {
+	ctx      *OperationContext
+	interval time.Duration
+
+	mu         sync.Mutex
+	started    time.Time
+	lastUpdate time.Time
+	message    *discordgo.Message
+	done       bool
+}
+
+func newProgressReporter(ctx *OperationContext) *ProgressReporter {
+	now := time.Now()
+	return &ProgressReporter{
+		ctx:        ctx,
+		interval:   Instance.config.ProgressInterval,
+		started:    now,
+		lastUpdate: now,
+	}
+}
+
+// withProgressReporter returns a copy of ctx carrying the given ProgressReporter.
+func withProgressReporter(ctx context.Context, reporter *ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressKey{}, reporter)
+}
+
+// ReportProgress updates the progress shown for the job running under ctx, if any.
+// preview may be nil, or an intermediate frame to show alongside the status.
+func ReportProgress(ctx context.Context, status string, preview *imagick.MagickWand) {
+	reporter, ok := ctx.Value(progressKey{}).(*ProgressReporter)
+	if !ok {
+		return
+	}
+	reporter.Report(status, preview)
+}
+
+// Report shows a new status, unless an update was made too recently.
+func (p *ProgressReporter) Report(status string, preview *imagick.MagickWand) {
+	if p.interval <= 0 {
+		return
+	}
+
+	// Progress updates are lossy - if another update is already being sent, this one is dropped.
+	if !p.mu.TryLock() {
+		return
+	}
+	defer p.mu.Unlock()
+
+	if p.done || p.ctx.responded.Load() || time.Since(p.lastUpdate) < p.interval {
+		return
+	}
+	p.lastUpdate = time.Now()
+
+	var files []*discordgo.File
+	if preview != nil {
+		blob, err := encodeProgressPreview(preview)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to encode progress preview")
+		} else {
+			files = []*discordgo.File{{Name: "preview.png", ContentType: "image/png", Reader: bytes.NewReader(blob)}}
+		}
+	}
+
+	content := fmt.Sprintf("%s (%s elapsed)", status, time.Since(p.started).Round(time.Second))
+
+	var err error
+	switch {
+	case p.ctx.Message == nil:
+		_, err = p.ctx.Session.InteractionResponseEdit(p.ctx.Interaction.Interaction, &discordgo.WebhookEdit{
+			Content:     &content,
+			Files:       files,
+			Attachments: &[]*discordgo.MessageAttachment{},
+		})
+	case p.message == nil:
+		p.message, err = p.ctx.Session.ChannelMessageSendComplex(p.ctx.Message.ChannelID, &discordgo.MessageSend{
+			Content:   content,
+			Reference: p.ctx.Message.Reference(),
+			Files:     files,
+		})
+	default:
+		_, err = p.ctx.Session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:          p.message.ID,
+			Channel:     p.message.ChannelID,
+			Content:     &content,
+			Files:       files,
+			Attachments: &[]*discordgo.MessageAttachment{},
+		})
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to update progress")
+	}
+}
+
+// Finish stops any further updates, removing the status message if one was sent.
+// Interaction responses are left as-is, as they are replaced by the result.
+func (p *ProgressReporter) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done = true
+	if p.message == nil {
+		return
+	}
+
+	if err := p.ctx.Session.ChannelMessageDelete(p.message.ChannelID, p.message.ID); err != nil {
+		log.Error().Err(err).Msg("Failed to delete progress message")
+	}
+}
+
+// encodeProgressPreview produces a small PNG of a frame to attach to a progress update.
+func encodeProgressPreview(frame *imagick.MagickWand) ([]byte, error) {
+	preview := frame.Clone()
+	defer preview.Destroy()
+
+	if err := ShrinkMaintainAspectRatio(preview, progressPreviewSize, progressPreviewSize); err != nil {
+		return nil, fmt.Errorf("error resizing preview: %w", err)
+	}
+	if err := preview.SetImageFormat("PNG"); err != nil {
+		return nil, fmt.Errorf("error setting preview format: %w", err)
+	}
+
+	return preview.GetImageBlob()
+}
@@ -1,7 +1,9 @@
 package bot
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
@@ -9,8 +11,8 @@ import (
 type ResizeArgs struct {
 	Width    float64 `description:"Width in pixels (absolute) or percent (e.g. 150 = 150%)."`
 	Height   float64 `description:"Height in pixels (absolute) or percent (e.g. 150 = 150%)."`
-	ImageURL string  `default:"" description:"Image URL to process. Leave blank to auto-find."`
-	Mode     string  `default:"percent" description:"Resize mode (percent/absolute) for width/height values."`
+	ImageURL string  `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
+	Mode     string  `default:"percent" choices:"percent,absolute" description:"Resize mode (percent/absolute) for width/height values."`
 }
 
 func (args ResizeArgs) GetImageURL() string {
@@ -18,9 +20,9 @@ func (args ResizeArgs) GetImageURL() string {
 }
 
 // Resize resizes an image.
-func Resize(wand *imagick.MagickWand, args ResizeArgs) ([]*imagick.MagickWand, error) {
+func Resize(_ context.Context, wand *imagick.MagickWand, args ResizeArgs) ([]*imagick.MagickWand, error) {
 	var targetHeight, targetWidth uint
-	switch args.Mode {
+	switch strings.ToLower(args.Mode) {
 	case "absolute":
 		targetHeight = uint(args.Height)
 		targetWidth = uint(args.Width)
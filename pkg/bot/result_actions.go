@@ -0,0 +1,339 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// resultActionPrefix prefixes the custom ID of every component attached to a result.
+const resultActionPrefix = "result"
+
+const (
+	resultActionAgain    = "again"
+	resultActionStronger = "stronger"
+	resultActionWeaker   = "weaker"
+	resultActionChain    = "chain"
+	resultActionDelete   = "delete"
+)
+
+// primaryArgFactor is how much the Stronger and Weaker buttons scale an operation's primary argument by.
+const primaryArgFactor = 1.5
+
+// maxChainMenuOptions is the most options Discord allows in a select menu.
+const maxChainMenuOptions = 25
+
+// chainMenuOperations lists the operations offered when chaining from a result, in the order they are registered.
+var chainMenuOperations []string
+
+// rerunFunc invokes the operation that produced a result again, with the given arguments.
+type rerunFunc func(ctx *OperationContext, args ImageOperationArgs)
+
+// resultState holds everything needed to follow up on a result from the buttons attached to it.
+type resultState struct {
+	id        string
+	command   string
+	args      ImageOperationArgs
+	rerun     rerunFunc
+	userID    string
+	inputURL  string
+	outputURL string
+	created   time.Time
+}
+
+// resultStateStore holds the state of recent results, keyed by the ID embedded in their components.
+type resultStateStore struct {
+	mu     sync.Mutex
+	states map[string]*resultState
+}
+
+var resultStates = &resultStateStore{states: map[string]*resultState{}}
+
+// resultSequence numbers the results produced by each run of an operation, as a run can post several.
+var resultSequence atomic.Uint64
+
+// add stores the state of a result, dropping any that have expired.
+func (s *resultStateStore) add(state *resultState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, existing := range s.states {
+		if time.Since(existing.created) > Instance.config.ResultActionsMaxAge {
+			delete(s.states, id)
+		}
+	}
+	s.states[state.id] = state
+}
+
+// get returns the state of a result, if it is known and hasn't expired.
+func (s *resultStateStore) get(id string) (*resultState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[id]
+	if !ok || time.Since(state.created) > Instance.config.ResultActionsMaxAge {
+		return nil, false
+	}
+	return state, true
+}
+
+// newResultState captures how a result was produced, returning nil if buttons can't be offered for it.
+// Each result gets its own ID, made unique across restarts by the ID of the message or interaction that ran it.
+func newResultState(ctx *OperationContext, args ImageOperationArgs, inputURL string) *resultState {
+	if Instance.config.ResultActionsMaxAge <= 0 || ctx.rerun == nil {
+		return nil
+	}
+
+	return &resultState{
+		id:       fmt.Sprintf("%s-%d", ctx.GetSourceID(), resultSequence.Add(1)),
+		command:  ctx.GetCommandName(),
+		args:     args,
+		rerun:    ctx.rerun,
+		userID:   ctx.GetUserID(),
+		inputURL: inputURL,
+		created:  time.Now(),
+	}
+}
+
+// save records the state once its result has been sent, so that its buttons can be used.
+func (state *resultState) save(message *discordgo.Message) {
+	if state == nil || message == nil {
+		return
+	}
+	state.outputURL = attachmentURL(message)
+	resultStates.add(state)
+}
+
+func (state *resultState) customID(action string) string {
+	return strings.Join([]string{resultActionPrefix, action, state.id}, ":")
+}
+
+// components builds the buttons and menus attached to a result.
+func (state *resultState) components() []discordgo.MessageComponent {
+	if state == nil {
+		return nil
+	}
+
+	buttons := []discordgo.MessageComponent{
+		discordgo.Button{Label: "Again", Style: discordgo.PrimaryButton, CustomID: state.customID(resultActionAgain)},
+	}
+	if _, ok := primaryArgField(state.args); ok {
+		buttons = append(
+			buttons,
+			discordgo.Button{
+				Label:    "Stronger",
+				Style:    discordgo.SecondaryButton,
+				CustomID: state.customID(resultActionStronger),
+			},
+			discordgo.Button{
+				Label:    "Weaker",
+				Style:    discordgo.SecondaryButton,
+				CustomID: state.customID(resultActionWeaker),
+			},
+		)
+	}
+	buttons = append(
+		buttons,
+		discordgo.Button{Label: "Delete", Style: discordgo.DangerButton, CustomID: state.customID(resultActionDelete)},
+	)
+
+	components := []discordgo.MessageComponent{discordgo.ActionsRow{Components: buttons}}
+
+	var options []discordgo.SelectMenuOption
+	for _, name := range chainMenuOperations {
+		if name == state.command {
+			continue
+		}
+		options = append(options, discordgo.SelectMenuOption{Label: name, Value: name})
+		if len(options) == maxChainMenuOptions {
+			break
+		}
+	}
+	if len(options) > 0 {
+		components = append(components, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					MenuType:    discordgo.StringSelectMenu,
+					CustomID:    state.customID(resultActionChain),
+					Placeholder: "Chain…",
+					Options:     options,
+				},
+			},
+		})
+	}
+
+	return components
+}
+
+// primaryArgField returns the index of the numeric argument tagged as the primary argument of an operation.
+func primaryArgField(args ImageOperationArgs) (int, bool) {
+	argsType := reflect.TypeOf(args)
+	if argsType == nil || argsType.Kind() != reflect.Struct {
+		return 0, false
+	}
+
+	for index := 0; index < argsType.NumField(); index++ {
+		field := argsType.Field(index)
+		if field.Tag.Get("primary") != "true" {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return index, true
+		}
+	}
+	return 0, false
+}
+
+// scalePrimaryArg returns a copy of args with its primary argument multiplied by factor.
+// Integer arguments always change by at least one, and unsigned arguments never drop below one.
+func scalePrimaryArg(args ImageOperationArgs, factor float64) (ImageOperationArgs, bool) {
+	index, ok := primaryArgField(args)
+	if !ok {
+		return nil, false
+	}
+
+	scaled := reflect.New(reflect.TypeOf(args)).Elem()
+	scaled.Set(reflect.ValueOf(args))
+	field := scaled.Field(index)
+
+	step := int64(1)
+	if factor < 1 {
+		step = -1
+	}
+
+	switch field.Kind() {
+	case reflect.Float32, reflect.Float64:
+		field.SetFloat(field.Float() * factor)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		current := field.Int()
+		next := int64(math.Round(float64(current) * factor))
+		if next == current {
+			next += step
+		}
+		field.SetInt(next)
+	default:
+		current := int64(field.Uint())
+		next := int64(math.Round(float64(current) * factor))
+		if next == current {
+			next += step
+		}
+		field.SetUint(uint64(max(1, next)))
+	}
+
+	return scaled.Interface().(ImageOperationArgs), true
+}
+
+// parseResultCustomID splits the custom ID of a result component into its action and state ID.
+func parseResultCustomID(customID string) (action string, stateID string, ok bool) {
+	parts := strings.Split(customID, ":")
+	if len(parts) != 3 || parts[0] != resultActionPrefix {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// handleResultComponent handles the buttons and menus attached to results.
+func handleResultComponent(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	data := interaction.MessageComponentData()
+	action, stateID, ok := parseResultCustomID(data.CustomID)
+	if !ok {
+		return
+	}
+
+	ctx := NewOperationContextFromInteraction(session, interaction)
+
+	state, ok := resultStates.get(stateID)
+	if !ok {
+		ctx.ReportError(newUserError("This result is too old for its buttons to work. Run the command again instead."))
+		return
+	}
+
+	switch action {
+	case resultActionAgain:
+		rerunResult(ctx, state, state.args)
+	case resultActionStronger, resultActionWeaker:
+		factor := primaryArgFactor
+		if action == resultActionWeaker {
+			factor = 1 / primaryArgFactor
+		}
+		args, ok := scalePrimaryArg(state.args, factor)
+		if !ok {
+			ctx.ReportError(newUserError("That command doesn't have anything to make stronger or weaker."))
+			return
+		}
+		rerunResult(ctx, state, args)
+	case resultActionChain:
+		if len(data.Values) == 0 {
+			return
+		}
+		chainFromResult(ctx, state, data.Values[0])
+	case resultActionDelete:
+		deleteResult(ctx, state)
+	default:
+		log.Warn().Str("custom_id", data.CustomID).Msg("Unknown result action")
+	}
+}
+
+// rerunResult runs the command that produced a result again on the same input, with the given arguments.
+func rerunResult(ctx *OperationContext, state *resultState, args ImageOperationArgs) {
+	ctx.commandName = state.command
+	ctx.sourceURL = state.inputURL
+	state.rerun(ctx, args)
+}
+
+// chainFromResult runs another operation, with its default arguments, on a result.
+func chainFromResult(ctx *OperationContext, state *resultState, name string) {
+	operation, ok := operationRegistry[name]
+	if !ok {
+		ctx.ReportError(newUserError("I don't know the operation %s.", name))
+		return
+	}
+	if state.outputURL == "" {
+		ctx.ReportError(newUserError("I couldn't find the result to chain from."))
+		return
+	}
+
+	args, err := operation.parseArgs(nil)
+	if err != nil {
+		ctx.ReportError(withUserMessage(
+			fmt.Errorf("error parsing default arguments: %w", err),
+			fmt.Sprintf("%s needs arguments, so it can't be chained from here.", name),
+		))
+		return
+	}
+
+	ctx.commandName = name
+	ctx.sourceURL = state.outputURL
+	PrepareAndInvokeOperation(ctx, args, operation.run)
+}
+
+// deleteResult deletes a result message, if the user who asked is the one who requested it.
+func deleteResult(ctx *OperationContext, state *resultState) {
+	if ctx.GetUserID() != state.userID {
+		ctx.ReportError(newUserError("Only the person who ran this command can delete its result."))
+		return
+	}
+
+	err := ctx.Session.InteractionRespond(ctx.Interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to acknowledge delete")
+		return
+	}
+
+	message := ctx.Interaction.Message
+	if err := ctx.Session.ChannelMessageDelete(message.ChannelID, message.ID); err != nil {
+		log.Error().Err(err).Msg("Failed to delete result")
+	}
+}
@@ -0,0 +1,266 @@
+package bot
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	configPkg "github.com/fogo-sh/borik/pkg/config"
+)
+
+func TestNewResultStateGivesEachResultItsOwnID(t *testing.T) {
+	previous := Instance
+	Instance = &Bot{config: &configPkg.Config{ResultActionsMaxAge: time.Hour}}
+	t.Cleanup(func() { Instance = previous })
+
+	ctx := &OperationContext{
+		Message: &discordgo.MessageCreate{Message: &discordgo.Message{
+			ID:     "1234",
+			Author: &discordgo.User{ID: "5678"},
+		}},
+		commandName: "magik",
+		rerun:       func(*OperationContext, ImageOperationArgs) {},
+	}
+
+	first := newResultState(ctx, MagikArgs{Scale: 1}, "")
+	second := newResultState(ctx, MagikArgs{Scale: 1}, "")
+	if first.id == second.id {
+		t.Fatalf("two results from the same run share the ID %q", first.id)
+	}
+
+	for _, state := range []*resultState{first, second} {
+		action, stateID, ok := parseResultCustomID(state.customID(resultActionStronger))
+		if !ok || action != resultActionStronger || stateID != state.id {
+			t.Errorf("custom ID for %q parsed as (%q, %q, %v)", state.id, action, stateID, ok)
+		}
+	}
+}
+
+// intPrimaryArgs has a signed integer primary argument.
+type intPrimaryArgs struct {
+	Amount int `primary:"true"`
+}
+
+func (args intPrimaryArgs) GetImageURL() string { return "" }
+
+// uintPrimaryArgs has an unsigned integer primary argument.
+type uintPrimaryArgs struct {
+	Amount uint `primary:"true"`
+}
+
+func (args uintPrimaryArgs) GetImageURL() string { return "" }
+
+// textPrimaryArgs has a primary argument that isn't numeric.
+type textPrimaryArgs struct {
+	Text string `primary:"true"`
+}
+
+func (args textPrimaryArgs) GetImageURL() string { return "" }
+
+// noPrimaryArgs has a numeric argument that isn't tagged as the primary argument.
+type noPrimaryArgs struct {
+	Amount float64
+}
+
+func (args noPrimaryArgs) GetImageURL() string { return "" }
+
+func TestScalePrimaryArg(t *testing.T) {
+	stronger, weaker := primaryArgFactor, 1/primaryArgFactor
+	tests := []struct {
+		name   string
+		args   ImageOperationArgs
+		factor float64
+		want   ImageOperationArgs
+	}{
+		{name: "float stronger", args: MagikArgs{Scale: 2}, factor: stronger, want: MagikArgs{Scale: 3}},
+		{name: "float weaker", args: MagikArgs{Scale: 3}, factor: weaker, want: MagikArgs{Scale: 2}},
+		{name: "int stronger", args: intPrimaryArgs{Amount: 4}, factor: stronger, want: intPrimaryArgs{Amount: 6}},
+		{name: "int weaker", args: intPrimaryArgs{Amount: 4}, factor: weaker, want: intPrimaryArgs{Amount: 3}},
+		{name: "int stronger from zero", args: intPrimaryArgs{}, factor: stronger, want: intPrimaryArgs{Amount: 1}},
+		{name: "int weaker from zero", args: intPrimaryArgs{}, factor: weaker, want: intPrimaryArgs{Amount: -1}},
+		{name: "uint stronger", args: uintPrimaryArgs{Amount: 3}, factor: stronger, want: uintPrimaryArgs{Amount: 5}},
+		{name: "uint weaker", args: uintPrimaryArgs{Amount: 3}, factor: weaker, want: uintPrimaryArgs{Amount: 2}},
+		{name: "uint weaker at one", args: uintPrimaryArgs{Amount: 1}, factor: weaker, want: uintPrimaryArgs{Amount: 1}},
+		{name: "uint stronger at one", args: uintPrimaryArgs{Amount: 1}, factor: stronger, want: uintPrimaryArgs{Amount: 2}},
+		{name: "primary argument isn't numeric", args: textPrimaryArgs{Text: "hello"}, factor: stronger},
+		{name: "no primary argument", args: noPrimaryArgs{Amount: 2}, factor: stronger},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := scalePrimaryArg(test.args, test.factor)
+			if ok != (test.want != nil) {
+				t.Fatalf("scalePrimaryArg(%+v) ok = %v, want %v", test.args, ok, test.want != nil)
+			}
+			if !ok {
+				return
+			}
+
+			if gotMagik, isMagik := got.(MagikArgs); isMagik {
+				wantMagik := test.want.(MagikArgs)
+				if math.Abs(gotMagik.Scale-wantMagik.Scale) > 1e-9 {
+					t.Errorf("scalePrimaryArg(%+v) = %+v, want %+v", test.args, got, test.want)
+				}
+				return
+			}
+			if got != test.want {
+				t.Errorf("scalePrimaryArg(%+v) = %+v, want %+v", test.args, got, test.want)
+			}
+		})
+	}
+}
+
+func TestScalePrimaryArgLeavesOriginal(t *testing.T) {
+	args := MagikArgs{Scale: 2}
+	if _, ok := scalePrimaryArg(args, primaryArgFactor); !ok {
+		t.Fatal("MagikArgs has no primary argument")
+	}
+	if args.Scale != 2 {
+		t.Errorf("original arguments were changed to %+v", args)
+	}
+}
+
+// discordRequest is a request made to the Discord API in a test.
+type discordRequest struct {
+	method string
+	path   string
+	body   string
+}
+
+// testDiscord stands in for the Discord API, recording each request and answering it with no content.
+type testDiscord struct {
+	mu       sync.Mutex
+	requests []discordRequest
+}
+
+func (d *testDiscord) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	d.mu.Lock()
+	d.requests = append(d.requests, discordRequest{method: req.Method, path: req.URL.Path, body: string(body)})
+	d.mu.Unlock()
+
+	return &http.Response{
+		StatusCode: http.StatusNoContent,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+// newTestSession creates a session whose requests are sent to a testDiscord.
+func newTestSession(t *testing.T) (*discordgo.Session, *testDiscord) {
+	t.Helper()
+
+	session, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatalf("error creating session: %v", err)
+	}
+	discord := &testDiscord{}
+	session.Client = &http.Client{Transport: discord}
+	return session, discord
+}
+
+// newResultComponentInteraction creates an interaction for a user pressing a result's button.
+func newResultComponentInteraction(userID string, customID string) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:     "interaction",
+		Token:  "token",
+		Type:   discordgo.InteractionMessageComponent,
+		Data:   discordgo.MessageComponentInteractionData{CustomID: customID},
+		Member: &discordgo.Member{User: &discordgo.User{ID: userID}},
+		Message: &discordgo.Message{
+			ID:        "result",
+			ChannelID: "channel",
+		},
+	}}
+}
+
+func TestDeleteResultOnlyForRequester(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     string
+		wantDelete bool
+	}{
+		{name: "requester", userID: "requester", wantDelete: true},
+		{name: "someone else", userID: "someone else", wantDelete: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session, discord := newTestSession(t)
+			state := &resultState{id: "state", userID: "requester"}
+			interaction := newResultComponentInteraction(test.userID, state.customID(resultActionDelete))
+
+			deleteResult(NewOperationContextFromInteraction(session, interaction), state)
+
+			var deleted, refused bool
+			for _, request := range discord.requests {
+				if request.method == http.MethodDelete && request.path == "/api/v9/channels/channel/messages/result" {
+					deleted = true
+				}
+				if strings.Contains(request.body, "Only the person who ran this command can delete its result.") {
+					refused = true
+				}
+			}
+			if deleted != test.wantDelete {
+				t.Errorf("result deleted = %v, want %v", deleted, test.wantDelete)
+			}
+			if refused == test.wantDelete {
+				t.Errorf("refusal sent = %v, want %v", refused, !test.wantDelete)
+			}
+		})
+	}
+}
+
+func TestResultComponentRerunsWithStoredState(t *testing.T) {
+	previous := Instance
+	Instance = &Bot{config: &configPkg.Config{ResultActionsMaxAge: time.Hour}}
+	t.Cleanup(func() { Instance = previous })
+
+	var gotCtx *OperationContext
+	var gotArgs ImageOperationArgs
+	state := &resultState{
+		id:       "rerun-test",
+		command:  "magik",
+		args:     MagikArgs{Scale: 2, ImageURL: "https://example.com/input.png"},
+		userID:   "requester",
+		inputURL: "https://example.com/input.png",
+		created:  time.Now(),
+		rerun: func(ctx *OperationContext, args ImageOperationArgs) {
+			gotCtx, gotArgs = ctx, args
+		},
+	}
+	resultStates.add(state)
+	t.Cleanup(func() {
+		resultStates.mu.Lock()
+		delete(resultStates.states, state.id)
+		resultStates.mu.Unlock()
+	})
+
+	interaction := newResultComponentInteraction("someone else", state.customID(resultActionStronger))
+	handleResultComponent(nil, interaction)
+
+	if gotCtx == nil {
+		t.Fatal("the result wasn't run again")
+	}
+	if gotCtx.GetCommandName() != "magik" || gotCtx.sourceURL != state.inputURL {
+		t.Errorf("ran %q on %q, want %q on %q", gotCtx.GetCommandName(), gotCtx.sourceURL, "magik", state.inputURL)
+	}
+	want := MagikArgs{Scale: 3, ImageURL: "https://example.com/input.png"}
+	if gotArgs != want {
+		t.Errorf("ran with %+v, want %+v", gotArgs, want)
+	}
+}
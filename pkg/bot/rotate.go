@@ -1,13 +1,14 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
 
 type RotateArgs struct {
-	ImageURL string  `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL string  `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 	Degrees  float64 `default:"90" description:"Number of degrees to rotate the image by."`
 }
 
@@ -16,7 +17,7 @@ func (args RotateArgs) GetImageURL() string {
 }
 
 // Rotate rotates an image.
-func Rotate(wand *imagick.MagickWand, args RotateArgs) ([]*imagick.MagickWand, error) {
+func Rotate(_ context.Context, wand *imagick.MagickWand, args RotateArgs) ([]*imagick.MagickWand, error) {
 	bgWand := imagick.NewPixelWand()
 	bgWand.SetAlpha(0)
 
@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// slashCommands contains the commands registered as top-level slash commands, keyed by the name they're run with.
+var slashCommands = map[string]*Command{}
+
+// addSlashCommand adds a command as a top-level slash command, under each of its names and aliases.
+func addSlashCommand(command *Command) {
+	for _, name := range slices.Concat([]string{command.name}, command.aliases, command.slashAliases) {
+		slashCommands[name] = command
+	}
+}
+
+// slashCommandDefinitions builds the top-level slash command of each command that isn't grouped into a category.
+func slashCommandDefinitions() ([]*discordgo.ApplicationCommand, error) {
+	var commands []*discordgo.ApplicationCommand
+	for _, name := range slices.Sorted(maps.Keys(slashCommands)) {
+		command := slashCommands[name]
+		options, err := slashOptions(reflect.TypeOf(command.slashHandler).In(2))
+		if err != nil {
+			return nil, fmt.Errorf("error generating options for %s: %w", name, err)
+		}
+
+		commands = append(commands, &discordgo.ApplicationCommand{
+			Type:        discordgo.ChatApplicationCommand,
+			Name:        name,
+			Description: truncateSlashDescription(command.description),
+			Options:     options,
+		})
+	}
+	return commands, nil
+}
+
+// syncApplicationCommands registers every slash command, category and context menu command with a single bulk
+// overwrite. Registering them together replaces whatever was registered before in one request, rather than leaving
+// commands missing or stale between requests and counting each one towards Discord's daily limit on creating commands.
+func syncApplicationCommands(
+	session *discordgo.Session,
+	appID string,
+	guildID string,
+	contextMenuNames []string,
+) error {
+	commands, err := slashCommandDefinitions()
+	if err != nil {
+		return err
+	}
+	groups, err := slashCommandGroupDefinitions()
+	if err != nil {
+		return err
+	}
+	commands = slices.Concat(commands, groups, contextMenuCommandDefinitions(contextMenuNames))
+
+	_, err = session.ApplicationCommandBulkOverwrite(appID, guildID, commands)
+	if err != nil {
+		return fmt.Errorf("error overwriting commands: %w", err)
+	}
+	return nil
+}
+
+// slashCommandFor returns the command a slash command interaction runs, along with the options it was given,
+// or nil if it isn't one of the registered commands.
+func slashCommandFor(
+	data discordgo.ApplicationCommandInteractionData,
+) (*Command, []*discordgo.ApplicationCommandInteractionDataOption) {
+	if command := groupedSlashCommand(data); command != nil {
+		return command, data.Options[0].Options
+	}
+	return slashCommands[data.Name], data.Options
+}
+
+// handleSlashCommand runs the command for a slash command or a slash subcommand of a category.
+func handleSlashCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	data := interaction.ApplicationCommandData()
+	command, options := slashCommandFor(data)
+	if command == nil {
+		return
+	}
+
+	handler := reflect.ValueOf(command.slashHandler)
+	args, err := slashArgs(handler.Type().In(2), options, data.Resolved)
+	if err != nil {
+		ctx := NewOperationContextFromInteraction(session, interaction)
+		ctx.ReportError(&UserError{Message: fmt.Sprintf("I couldn't use those arguments: %s.", err), Err: err})
+		return
+	}
+
+	handler.Call([]reflect.Value{reflect.ValueOf(session), reflect.ValueOf(interaction), args})
+}
@@ -0,0 +1,235 @@
+package bot
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// maxSubcommands is the most subcommands Discord allows a slash command to have.
+const maxSubcommands = 25
+
+// maxSlashDescriptionLength is the longest description Discord allows a slash command or option to have.
+const maxSlashDescriptionLength = 100
+
+// slashCommandGroup is a slash command with a subcommand for each command in a category.
+type slashCommandGroup struct {
+	category *commandCategory
+	// names lists the subcommands in the order they were added.
+	names       []string
+	subcommands map[string]*Command
+}
+
+// slashCommandGroups contains the slash command of each category, keyed by the category's name.
+var slashCommandGroups = map[string]*slashCommandGroup{}
+
+// addSlashSubcommands adds a command, and each of its aliases, as subcommands of its category's slash command.
+func addSlashSubcommands(command *Command) {
+	group, ok := slashCommandGroups[command.category.name]
+	if !ok {
+		group = &slashCommandGroup{category: command.category, subcommands: map[string]*Command{}}
+		slashCommandGroups[command.category.name] = group
+	}
+
+	for _, name := range slices.Concat([]string{command.name}, command.aliases, command.slashAliases) {
+		subcommand := strings.TrimPrefix(name, command.category.prefix)
+		if len(group.names) == maxSubcommands {
+			log.Warn().
+				Str("command", name).
+				Str("category", command.category.name).
+				Msgf("Skipping subcommand beyond the first %d", maxSubcommands)
+			continue
+		}
+		group.names = append(group.names, subcommand)
+		group.subcommands[subcommand] = command
+	}
+}
+
+// slashCommandGroupDefinitions builds the slash command of each category, with a subcommand for each of its commands.
+func slashCommandGroupDefinitions() ([]*discordgo.ApplicationCommand, error) {
+	var commands []*discordgo.ApplicationCommand
+	for _, name := range slices.Sorted(maps.Keys(slashCommandGroups)) {
+		group := slashCommandGroups[name]
+		command := &discordgo.ApplicationCommand{
+			Type:        discordgo.ChatApplicationCommand,
+			Name:        group.category.name,
+			Description: truncateSlashDescription(group.category.description),
+		}
+
+		for _, subcommand := range group.names {
+			argsType := reflect.TypeOf(group.subcommands[subcommand].slashHandler).In(2)
+			options, err := slashOptions(argsType)
+			if err != nil {
+				return nil, fmt.Errorf("error generating options for %s %s: %w", name, subcommand, err)
+			}
+			command.Options = append(command.Options, &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        subcommand,
+				Description: truncateSlashDescription(group.subcommands[subcommand].description),
+				Options:     options,
+			})
+		}
+
+		commands = append(commands, command)
+	}
+
+	return commands, nil
+}
+
+// truncateSlashDescription shortens a description to the longest Discord allows.
+func truncateSlashDescription(description string) string {
+	if description == "" {
+		return "-"
+	}
+	if runes := []rune(description); len(runes) > maxSlashDescriptionLength {
+		return string(runes[:maxSlashDescriptionLength-1]) + "…"
+	}
+	return description
+}
+
+// slashOptionName converts the name of an argument struct field to the name of a slash command option,
+// such as ImageURL to image_url. A trailing s after an acronym, as in ImageURLs, is kept with it.
+func slashOptionName(field string) string {
+	runes := []rune(field)
+	var name strings.Builder
+	for index, r := range runes {
+		if index > 0 && unicode.IsUpper(r) {
+			previous := runes[index-1]
+			pluralAcronym := index+2 == len(runes) && runes[index+1] == 's'
+			nextIsLower := index+1 < len(runes) && unicode.IsLower(runes[index+1]) && !pluralAcronym
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				name.WriteRune('_')
+			}
+		}
+		name.WriteRune(unicode.ToLower(r))
+	}
+	return name.String()
+}
+
+// slashOptionType returns the type of slash command option an argument of the given type is given with.
+func slashOptionType(argType reflect.Type) (discordgo.ApplicationCommandOptionType, bool) {
+	switch argType {
+	case reflect.TypeFor[*discordgo.MessageAttachment]():
+		return discordgo.ApplicationCommandOptionAttachment, true
+	case reflect.TypeFor[*discordgo.User]():
+		return discordgo.ApplicationCommandOptionUser, true
+	}
+
+	switch argType.Kind() {
+	case reflect.String:
+		return discordgo.ApplicationCommandOptionString, true
+	case reflect.Bool:
+		return discordgo.ApplicationCommandOptionBoolean, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return discordgo.ApplicationCommandOptionInteger, true
+	case reflect.Float32, reflect.Float64:
+		return discordgo.ApplicationCommandOptionNumber, true
+	default:
+		return 0, false
+	}
+}
+
+// slashOptions generates the options of a slash command or subcommand from the fields of its argument struct.
+// Pointer fields, which hold attachments and users, are optional, as are fields with a default.
+// Discord requires required options to come first, so they're moved ahead of the rest.
+func slashOptions(argsType reflect.Type) ([]*discordgo.ApplicationCommandOption, error) {
+	var required, optional []*discordgo.ApplicationCommandOption
+	for index := 0; index < argsType.NumField(); index++ {
+		field := argsType.Field(index)
+		optionType, ok := slashOptionType(field.Type)
+		if !ok {
+			return nil, fmt.Errorf("unsupported argument type %s", field.Type)
+		}
+
+		_, hasDefault := field.Tag.Lookup("default")
+		option := &discordgo.ApplicationCommandOption{
+			Type:        optionType,
+			Name:        slashOptionName(field.Name),
+			Description: truncateSlashDescription(field.Tag.Get("description")),
+			Required:    !hasDefault && field.Type.Kind() != reflect.Pointer,
+		}
+		if option.Required {
+			required = append(required, option)
+		} else {
+			optional = append(optional, option)
+		}
+	}
+
+	options := slices.Concat(required, optional)
+	applyOptionChoices(options, argsType)
+	return options, nil
+}
+
+// groupedSlashCommand returns the command a slash subcommand of a category runs, or nil if the interaction is for
+// some other command.
+func groupedSlashCommand(data discordgo.ApplicationCommandInteractionData) *Command {
+	group, ok := slashCommandGroups[data.Name]
+	if !ok || len(data.Options) == 0 || data.Options[0].Type != discordgo.ApplicationCommandOptionSubCommand {
+		return nil
+	}
+	return group.subcommands[data.Options[0].Name]
+}
+
+// slashCommandName returns the name of the command a slash command interaction is for, looking through the
+// subcommands of categories.
+func slashCommandName(data discordgo.ApplicationCommandInteractionData) string {
+	if command := groupedSlashCommand(data); command != nil {
+		return command.name
+	}
+	return data.Name
+}
+
+// slashArgs builds the argument struct of a slash command or subcommand from the options it was given.
+func slashArgs(
+	argsType reflect.Type,
+	options []*discordgo.ApplicationCommandInteractionDataOption,
+	resolved *discordgo.ApplicationCommandInteractionDataResolved,
+) (reflect.Value, error) {
+	argsValue := reflect.New(argsType).Elem()
+	for index := 0; index < argsType.NumField(); index++ {
+		fieldType := argsType.Field(index)
+		// Pointer fields, which hold attachments and users, are left nil by default.
+		if defaultValue, ok := fieldType.Tag.Lookup("default"); ok && fieldType.Type.Kind() != reflect.Pointer {
+			if err := setArgsField(argsValue.Field(index), defaultValue); err != nil {
+				return reflect.Value{}, fmt.Errorf("invalid default for argument %s: %w", fieldType.Name, err)
+			}
+		}
+	}
+
+	for _, option := range options {
+		fieldType, ok := findArgsFieldForOption(argsType, option.Name)
+		if !ok {
+			continue
+		}
+		field := argsValue.FieldByIndex(fieldType.Index)
+
+		var err error
+		switch option.Type {
+		case discordgo.ApplicationCommandOptionAttachment:
+			if resolved != nil {
+				field.Set(reflect.ValueOf(resolved.Attachments[fmt.Sprint(option.Value)]))
+			}
+		case discordgo.ApplicationCommandOptionUser:
+			if resolved != nil {
+				field.Set(reflect.ValueOf(resolved.Users[fmt.Sprint(option.Value)]))
+			}
+		case discordgo.ApplicationCommandOptionInteger:
+			err = setArgsField(field, strconv.FormatInt(option.IntValue(), 10))
+		default:
+			err = setArgsField(field, fmt.Sprint(option.Value))
+		}
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid value for argument %s: %w", fieldType.Name, err)
+		}
+	}
+
+	return argsValue, nil
+}
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestSlashOptionName(t *testing.T) {
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{field: "Scale", want: "scale"},
+		{field: "ImageURL", want: "image_url"},
+		{field: "OtherImageURL", want: "other_image_url"},
+		{field: "ImageURLs", want: "image_urls"},
+		{field: "VideoURL", want: "video_url"},
+		{field: "FPS", want: "fps"},
+		{field: "UseGuildAvatar", want: "use_guild_avatar"},
+		{field: "HTTPServer", want: "http_server"},
+		{field: "Scale2", want: "scale2"},
+		{field: "Step2Size", want: "step2_size"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.field, func(t *testing.T) {
+			if got := slashOptionName(test.field); got != test.want {
+				t.Errorf("slashOptionName(%q) = %q, want %q", test.field, got, test.want)
+			}
+			if !optionMatchesField(test.want, test.field) {
+				t.Errorf("option %q doesn't match the field %q it was generated from", test.want, test.field)
+			}
+		})
+	}
+}
+
+// testSlashArgs covers each kind of slash command option.
+type testSlashArgs struct {
+	Mode    string                       `default:"over" choices:"over,multiply" description:"Blend mode."`
+	Prompt  string                       `description:"Prompt to use."`
+	Command string                       `default:"" autocomplete:"commands" description:"Command to get help for."`
+	Scale   float64                      `default:"1.5" description:"Scale."`
+	Count   uint                         `description:"Count."`
+	Loop    bool                         `default:"true" description:"Loop."`
+	User    *discordgo.User              `description:"User."`
+	Image   *discordgo.MessageAttachment `description:"Image."`
+}
+
+func TestSlashOptions(t *testing.T) {
+	options, err := slashOptions(reflect.TypeFor[testSlashArgs]())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []struct {
+		name       string
+		optionType discordgo.ApplicationCommandOptionType
+		required   bool
+	}{
+		{"prompt", discordgo.ApplicationCommandOptionString, true},
+		{"count", discordgo.ApplicationCommandOptionInteger, true},
+		{"mode", discordgo.ApplicationCommandOptionString, false},
+		{"command", discordgo.ApplicationCommandOptionString, false},
+		{"scale", discordgo.ApplicationCommandOptionNumber, false},
+		{"loop", discordgo.ApplicationCommandOptionBoolean, false},
+		{"user", discordgo.ApplicationCommandOptionUser, false},
+		{"image", discordgo.ApplicationCommandOptionAttachment, false},
+	}
+	if len(options) != len(want) {
+		t.Fatalf("got %d options, want %d", len(options), len(want))
+	}
+	for index, option := range options {
+		if option.Name != want[index].name || option.Type != want[index].optionType ||
+			option.Required != want[index].required {
+			t.Errorf(
+				"option %d is (%s, %v, required %v), want (%s, %v, required %v)",
+				index, option.Name, option.Type, option.Required,
+				want[index].name, want[index].optionType, want[index].required,
+			)
+		}
+	}
+
+	if choices := options[2].Choices; len(choices) != 2 || choices[0].Value != "over" || choices[1].Value != "multiply" {
+		t.Errorf("mode has choices %v, want over and multiply", choices)
+	}
+	if !options[3].Autocomplete {
+		t.Error("command isn't autocompleted")
+	}
+}
+
+func TestSlashOptionsUnsupportedType(t *testing.T) {
+	type unsupportedArgs struct {
+		Names []string
+	}
+	if _, err := slashOptions(reflect.TypeFor[unsupportedArgs]()); err == nil {
+		t.Error("expected an error for an unsupported argument type")
+	}
+}
+
+func TestSlashArgs(t *testing.T) {
+	resolved := &discordgo.ApplicationCommandInteractionDataResolved{
+		Users:       map[string]*discordgo.User{"42": {ID: "42"}},
+		Attachments: map[string]*discordgo.MessageAttachment{"7": {ID: "7", URL: "https://example.com/a.png"}},
+	}
+	options := []*discordgo.ApplicationCommandInteractionDataOption{
+		{Name: "prompt", Type: discordgo.ApplicationCommandOptionString, Value: "a cat"},
+		{Name: "count", Type: discordgo.ApplicationCommandOptionInteger, Value: float64(3)},
+		{Name: "scale", Type: discordgo.ApplicationCommandOptionNumber, Value: 2.5},
+		{Name: "user", Type: discordgo.ApplicationCommandOptionUser, Value: "42"},
+		{Name: "image", Type: discordgo.ApplicationCommandOptionAttachment, Value: "7"},
+		{Name: "unknown", Type: discordgo.ApplicationCommandOptionString, Value: "ignored"},
+	}
+
+	value, err := slashArgs(reflect.TypeFor[testSlashArgs](), options, resolved)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	args := value.Interface().(testSlashArgs)
+
+	if args.Prompt != "a cat" || args.Count != 3 || args.Scale != 2.5 {
+		t.Errorf("got prompt %q, count %d and scale %v, want %q, 3 and 2.5", args.Prompt, args.Count, args.Scale, "a cat")
+	}
+	if args.Mode != "over" || !args.Loop || args.Command != "" {
+		t.Errorf("defaults weren't applied: got mode %q, loop %v and command %q", args.Mode, args.Loop, args.Command)
+	}
+	if args.User == nil || args.User.ID != "42" {
+		t.Errorf("got user %v, want the resolved user", args.User)
+	}
+	if args.Image == nil || args.Image.URL != "https://example.com/a.png" {
+		t.Errorf("got image %v, want the resolved attachment", args.Image)
+	}
+}
+
+func TestSlashArgsInvalidValue(t *testing.T) {
+	options := []*discordgo.ApplicationCommandInteractionDataOption{
+		{Name: "loop", Type: discordgo.ApplicationCommandOptionString, Value: "sometimes"},
+	}
+	if _, err := slashArgs(reflect.TypeFor[testSlashArgs](), options, nil); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+}
@@ -0,0 +1,119 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+type SwapFacesArgs struct {
+	ImageURL      string  `default:"" cache:"-" description:"URL to the image to paste onto, or #N to pick one from a message. Leave blank to auto-find."`
+	OtherImageURL string  `default:"" cache:"-" description:"URL to the image to take the centre of, or #N to pick one from a message. Blank to auto-find."`
+	Size          float64 `default:"50" description:"Size of the pasted centre, as a percentage of the first image."`
+	Feather       float64 `default:"10" description:"How much to soften the edge of the pasted centre, as a percentage of its size."`
+}
+
+func (args SwapFacesArgs) GetImageURLs() []string {
+	return []string{args.ImageURL, args.OtherImageURL}
+}
+
+// SwapFaces pastes the centre of the second image onto the centre of the first, through a soft-edged ellipse.
+// It doesn't detect faces, and relies on them being roughly centred in both images.
+func SwapFaces(_ context.Context, inputs []*imagick.MagickWand, args SwapFacesArgs) ([]*imagick.MagickWand, error) {
+	base := inputs[0]
+	baseWidth, baseHeight := base.GetImageWidth(), base.GetImageHeight()
+
+	size := max(1, min(args.Size, 100)) / 100
+	width := max(1, uint(float64(baseWidth)*size))
+	height := max(1, uint(float64(baseHeight)*size))
+
+	// Crop the largest area of the second image's centre with the same aspect ratio as the pasted region.
+	source := inputs[1]
+	sourceWidth, sourceHeight := source.GetImageWidth(), source.GetImageHeight()
+	cropWidth, cropHeight := sourceWidth, uint(float64(sourceWidth)*float64(height)/float64(width))
+	if cropHeight > sourceHeight {
+		cropWidth, cropHeight = uint(float64(sourceHeight)*float64(width)/float64(height)), sourceHeight
+	}
+	cropWidth, cropHeight = max(1, cropWidth), max(1, cropHeight)
+
+	face := source.Clone()
+	defer face.Destroy()
+
+	err := face.CropImage(cropWidth, cropHeight, int((sourceWidth-cropWidth)/2), int((sourceHeight-cropHeight)/2))
+	if err != nil {
+		return nil, fmt.Errorf("error while attempting to crop: %w", err)
+	}
+	err = face.ResetImagePage("0x0+0+0")
+	if err != nil {
+		return nil, fmt.Errorf("error while attempting to reset page: %w", err)
+	}
+	err = face.ResizeImage(width, height, imagick.FILTER_LANCZOS)
+	if err != nil {
+		return nil, fmt.Errorf("error while attempting to resize: %w", err)
+	}
+
+	mask, err := ellipseMask(width, height, max(0, args.Feather)/100)
+	if err != nil {
+		return nil, err
+	}
+	defer mask.Destroy()
+
+	err = face.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_ACTIVATE)
+	if err != nil {
+		return nil, fmt.Errorf("error enabling alpha channel: %w", err)
+	}
+	err = face.CompositeImage(mask, imagick.COMPOSITE_OP_COPY_ALPHA, true, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error applying mask: %w", err)
+	}
+
+	err = base.CompositeImage(
+		face,
+		imagick.COMPOSITE_OP_OVER,
+		true,
+		int((baseWidth-width)/2),
+		int((baseHeight-height)/2),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error compositing image: %w", err)
+	}
+
+	return []*imagick.MagickWand{base}, nil
+}
+
+// ellipseMask draws a white ellipse filling a black image of the given size, blurred by feather times its size.
+func ellipseMask(width uint, height uint, feather float64) (*imagick.MagickWand, error) {
+	black := imagick.NewPixelWand()
+	defer black.Destroy()
+	black.SetColor("black")
+	white := imagick.NewPixelWand()
+	defer white.Destroy()
+	white.SetColor("white")
+
+	mask := imagick.NewMagickWand()
+	err := mask.NewImage(width, height, black)
+	if err != nil {
+		mask.Destroy()
+		return nil, fmt.Errorf("error creating mask: %w", err)
+	}
+
+	blur := feather * float64(min(width, height)) / 2
+	rx, ry := float64(width)/2-blur, float64(height)/2-blur
+
+	draw := imagick.NewDrawingWand()
+	defer draw.Destroy()
+	draw.SetFillColor(white)
+	draw.Ellipse(float64(width)/2, float64(height)/2, max(1, rx), max(1, ry), 0, 360)
+
+	err = mask.DrawImage(draw)
+	if err == nil && blur > 0 {
+		err = mask.BlurImage(0, blur/2)
+	}
+	if err != nil {
+		mask.Destroy()
+		return nil, fmt.Errorf("error drawing mask: %w", err)
+	}
+
+	return mask, nil
+}
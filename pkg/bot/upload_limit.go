@@ -0,0 +1,294 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// premiumTierUploadLimits contains the upload limits granted to guilds by their boost level.
+// Guilds below tier 2 use the configured default upload limit.
+var premiumTierUploadLimits = map[discordgo.PremiumTier]int64{
+	discordgo.PremiumTier2: 50 * 1024 * 1024,
+	discordgo.PremiumTier3: 100 * 1024 * 1024,
+}
+
+// errResultTooLarge is returned when a result cannot be reduced to fit within the upload limit.
+var errResultTooLarge = errors.New("result is too large to upload")
+
+// GetUploadLimit returns the maximum size of a file that can be uploaded in response to this operation.
+// The guild's boost tier is read from the session's state when it's there, only fetching the guild otherwise.
+func (ctx *OperationContext) GetUploadLimit() int64 {
+	limit := Instance.config.UploadLimit
+
+	guildID := ctx.GetGuildID()
+	if guildID == "" {
+		return limit
+	}
+
+	guild, err := ctx.Session.State.Guild(guildID)
+	if err != nil {
+		guild, err = ctx.Session.Guild(guildID)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("guild", guildID).Msg("Failed to fetch guild to determine upload limit")
+		return limit
+	}
+
+	if tierLimit, ok := premiumTierUploadLimits[guild.PremiumTier]; ok && tierLimit > limit {
+		return tierLimit
+	}
+
+	return limit
+}
+
+// sizeBudget tracks the reductions made to a result image while fitting it within an upload limit.
+type sizeBudget struct {
+	wand *imagick.MagickWand
+
+	optimiseLayers bool
+	colours        uint
+
+	originalFrames uint
+	originalWidth  uint
+	originalHeight uint
+}
+
+// sizeBudgetStep applies a single reduction to a result image, returning whether it changed anything.
+type sizeBudgetStep func(*sizeBudget) (bool, error)
+
+// sizeBudgetSteps are the reductions applied to a result image, in order, until it fits within the upload limit.
+var sizeBudgetSteps = []sizeBudgetStep{
+	optimiseLayersStep,
+	reduceColoursStep(128),
+	reduceColoursStep(64),
+	dropFramesStep,
+	scaleStep(0.75),
+	reduceColoursStep(32),
+	dropFramesStep,
+	scaleStep(0.75),
+	dropFramesStep,
+	scaleStep(0.5),
+	scaleStep(0.5),
+}
+
+func optimiseLayersStep(budget *sizeBudget) (bool, error) {
+	if budget.optimiseLayers || budget.wand.GetNumberImages() < 2 {
+		return false, nil
+	}
+	budget.optimiseLayers = true
+	return true, nil
+}
+
+func reduceColoursStep(colours uint) sizeBudgetStep {
+	return func(budget *sizeBudget) (bool, error) {
+		if budget.colours != 0 && budget.colours <= colours {
+			return false, nil
+		}
+
+		err := budget.wand.QuantizeImages(colours, imagick.COLORSPACE_SRGB, 0, imagick.DITHER_METHOD_FLOYD_STEINBERG, false)
+		if err != nil {
+			return false, fmt.Errorf("error reducing colours: %w", err)
+		}
+		budget.colours = colours
+		return true, nil
+	}
+}
+
+// dropFramesStep removes every other frame, extending the delay of each remaining frame to keep the overall timing.
+func dropFramesStep(budget *sizeBudget) (bool, error) {
+	frameCount := int(budget.wand.GetNumberImages())
+	if frameCount < 3 {
+		return false, nil
+	}
+
+	reduced := imagick.NewMagickWand()
+	for i := 0; i < frameCount; i += 2 {
+		budget.wand.SetIteratorIndex(i)
+		frame := budget.wand.GetImage()
+		delay := frame.GetImageDelay()
+
+		if i+1 < frameCount {
+			budget.wand.SetIteratorIndex(i + 1)
+			delay += budget.wand.GetImageDelay()
+		}
+
+		if err := frame.SetImageDelay(delay); err != nil {
+			return false, fmt.Errorf("error setting frame delay: %w", err)
+		}
+		if err := reduced.AddImage(frame); err != nil {
+			return false, fmt.Errorf("error adding frame: %w", err)
+		}
+		frame.Destroy()
+	}
+
+	budget.wand.Destroy()
+	budget.wand = reduced
+	return true, nil
+}
+
+// scaleStep scales every frame by factor. Frames are only scaled once every one of them is known to be large enough,
+// so that a frame too small to shrink doesn't leave the rest half-scaled.
+func scaleStep(factor float64) sizeBudgetStep {
+	return func(budget *sizeBudget) (bool, error) {
+		frameCount := int(budget.wand.GetNumberImages())
+		widths, heights := make([]uint, frameCount), make([]uint, frameCount)
+		for i := 0; i < frameCount; i++ {
+			budget.wand.SetIteratorIndex(i)
+			widths[i] = uint(float64(budget.wand.GetImageWidth()) * factor)
+			heights[i] = uint(float64(budget.wand.GetImageHeight()) * factor)
+			if widths[i] == 0 || heights[i] == 0 {
+				return false, nil
+			}
+		}
+
+		for i := 0; i < frameCount; i++ {
+			budget.wand.SetIteratorIndex(i)
+			if err := budget.wand.ScaleImage(widths[i], heights[i]); err != nil {
+				return false, fmt.Errorf("error scaling frame: %w", err)
+			}
+			if err := budget.wand.ResetImagePage("0x0+0+0"); err != nil {
+				return false, fmt.Errorf("error repaging frame: %w", err)
+			}
+		}
+		return true, nil
+	}
+}
+
+// encode produces the final blob for the result image.
+func (budget *sizeBudget) encode() ([]byte, error) {
+	budget.wand.ResetIterator()
+
+	var encoded *imagick.MagickWand
+	if budget.optimiseLayers {
+		encoded = budget.wand.OptimizeImageLayers()
+		if err := encoded.OptimizeImageTransparency(); err != nil {
+			return nil, fmt.Errorf("error optimising transparency: %w", err)
+		}
+	} else {
+		encoded = budget.wand.DeconstructImages()
+	}
+	defer encoded.Destroy()
+
+	return encoded.GetImagesBlob()
+}
+
+// describe summarises the reductions that were made to the result image.
+func (budget *sizeBudget) describe() string {
+	var changes []string
+
+	if budget.optimiseLayers {
+		changes = append(changes, "optimised the animation layers")
+	}
+	if budget.colours != 0 {
+		changes = append(changes, fmt.Sprintf("reduced it to %d colours", budget.colours))
+	}
+	budget.wand.ResetIterator()
+	if frames := budget.wand.GetNumberImages(); frames != budget.originalFrames {
+		changes = append(changes, fmt.Sprintf("cut it from %d to %d frames", budget.originalFrames, frames))
+	}
+	width, height := budget.wand.GetImageWidth(), budget.wand.GetImageHeight()
+	if width != budget.originalWidth || height != budget.originalHeight {
+		changes = append(changes, fmt.Sprintf("shrank it to %dx%d", width, height))
+	}
+
+	switch len(changes) {
+	case 0:
+		return ""
+	case 1:
+		return changes[0]
+	default:
+		return strings.Join(changes[:len(changes)-1], ", ") + " and " + changes[len(changes)-1]
+	}
+}
+
+// fitToUploadLimit encodes a result image, progressively reducing its quality until it fits within limit bytes.
+// It returns the encoded image along with a description of any reductions that were needed.
+func fitToUploadLimit(wand *imagick.MagickWand, limit int64) ([]byte, string, error) {
+	wand.ResetIterator()
+	budget := &sizeBudget{
+		wand:           wand,
+		originalFrames: wand.GetNumberImages(),
+		originalWidth:  wand.GetImageWidth(),
+		originalHeight: wand.GetImageHeight(),
+	}
+
+	blob, err := budget.encode()
+	if err != nil {
+		return nil, "", fmt.Errorf("error encoding result: %w", err)
+	}
+
+	for _, step := range sizeBudgetSteps {
+		if int64(len(blob)) <= limit {
+			break
+		}
+
+		log.Debug().Int("size", len(blob)).Int64("limit", limit).Msg("Result exceeds upload limit, reducing")
+
+		applied, err := step(budget)
+		if err != nil {
+			return nil, "", err
+		}
+		if !applied {
+			continue
+		}
+
+		blob, err = budget.encode()
+		if err != nil {
+			return nil, "", fmt.Errorf("error encoding result: %w", err)
+		}
+	}
+
+	if int64(len(blob)) > limit {
+		return nil, "", errResultTooLarge
+	}
+
+	return blob, budget.describe(), nil
+}
+
+// fitBlobToUploadLimit reduces an already-encoded image until it fits within limit bytes.
+func fitBlobToUploadLimit(blob []byte, limit int64) ([]byte, string, error) {
+	if int64(len(blob)) <= limit {
+		return blob, "", nil
+	}
+
+	wand := imagick.NewMagickWand()
+	defer wand.Destroy()
+	if err := wand.ReadImageBlob(blob); err != nil {
+		return nil, "", fmt.Errorf("error reading image: %w", err)
+	}
+
+	return fitToUploadLimit(wand.CoalesceImages(), limit)
+}
+
+// uploadLimitMessage explains the reductions that were made to fit a result within the upload limit.
+func uploadLimitMessage(limit int64, reductions string) string {
+	if reductions == "" {
+		return ""
+	}
+	return fmt.Sprintf("The result was over the %s upload limit, so I %s.", formatByteSize(limit), reductions)
+}
+
+// uploadLimitError is the message sent when a result could not be reduced to fit within the upload limit.
+func uploadLimitError(limit int64) string {
+	return fmt.Sprintf(
+		"The result was too large to upload, even after reducing its quality to fit the %s limit.",
+		formatByteSize(limit),
+	)
+}
+
+// formatByteSize formats a number of bytes as a human-readable size, using the largest unit that keeps it above 1.
+func formatByteSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.0f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.0f KB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%d bytes", size)
+	}
+}
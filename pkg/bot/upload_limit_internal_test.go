@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"testing"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+func TestFormatByteSize(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{size: 0, want: "0 bytes"},
+		{size: 512, want: "512 bytes"},
+		{size: 1024, want: "1 KB"},
+		{size: 256 * 1024, want: "256 KB"},
+		{size: 1024 * 1024, want: "1 MB"},
+		{size: 8 * 1024 * 1024, want: "8 MB"},
+		{size: 25 * 1024 * 1024, want: "25 MB"},
+	}
+
+	for _, test := range tests {
+		if got := formatByteSize(test.size); got != test.want {
+			t.Errorf("formatByteSize(%d) = %q, want %q", test.size, got, test.want)
+		}
+	}
+}
+
+// newTestAnimation creates an animation with a frame of each of the given sizes.
+func newTestAnimation(t *testing.T, sizes ...[2]uint) *imagick.MagickWand {
+	t.Helper()
+
+	background := imagick.NewPixelWand()
+	defer background.Destroy()
+	background.SetColor("blue")
+
+	animation := imagick.NewMagickWand()
+	t.Cleanup(animation.Destroy)
+	for _, size := range sizes {
+		frame := imagick.NewMagickWand()
+		if err := frame.NewImage(size[0], size[1], background); err != nil {
+			t.Fatalf("error creating test frame: %v", err)
+		}
+		if err := animation.AddImage(frame); err != nil {
+			t.Fatalf("error adding test frame: %v", err)
+		}
+		frame.Destroy()
+	}
+	return animation
+}
+
+// frameSizes returns the size of each frame of an animation.
+func frameSizes(wand *imagick.MagickWand) [][2]uint {
+	sizes := make([][2]uint, wand.GetNumberImages())
+	for i := range sizes {
+		wand.SetIteratorIndex(i)
+		sizes[i] = [2]uint{wand.GetImageWidth(), wand.GetImageHeight()}
+	}
+	return sizes
+}
+
+func TestScaleStep(t *testing.T) {
+	budget := &sizeBudget{wand: newTestAnimation(t, [2]uint{40, 20}, [2]uint{40, 20})}
+
+	applied, err := scaleStep(0.5)(budget)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !applied {
+		t.Fatal("expected the frames to be scaled")
+	}
+	for index, size := range frameSizes(budget.wand) {
+		if size != [2]uint{20, 10} {
+			t.Errorf("frame %d is %v, want [20 10]", index, size)
+		}
+	}
+}
+
+func TestScaleStepLeavesFramesUnchangedWhenOneIsTooSmall(t *testing.T) {
+	budget := &sizeBudget{wand: newTestAnimation(t, [2]uint{40, 20}, [2]uint{40, 1})}
+
+	applied, err := scaleStep(0.5)(budget)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if applied {
+		t.Fatal("expected no scaling when a frame would shrink to nothing")
+	}
+
+	sizes := frameSizes(budget.wand)
+	if sizes[0] != [2]uint{40, 20} || sizes[1] != [2]uint{40, 1} {
+		t.Errorf("frames were changed to %v", sizes)
+	}
+}
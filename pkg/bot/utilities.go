@@ -2,6 +2,8 @@ package bot
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -10,8 +12,11 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"reflect"
 	"regexp"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -26,22 +31,52 @@ type ImageOperationArgs interface {
 var messageURLRegex = regexp.MustCompile(`(?i)https?://[^\s<>"']+`)
 
 type mediaType struct {
-	name         string
-	urlFromEmbed func(*discordgo.MessageEmbed) string
+	name                string
+	contentTypePrefixes []string
+	urlFromEmbed        func(*discordgo.MessageEmbed) string
 }
 
 var (
-	imageMediaType = mediaType{name: "image", urlFromEmbed: imageURLFromEmbed}
-	videoMediaType = mediaType{name: "video", urlFromEmbed: videoURLFromEmbed}
+	videoMediaType = mediaType{
+		name:                "video",
+		contentTypePrefixes: []string{"video/"},
+		urlFromEmbed:        videoURLFromEmbed,
+	}
+	// visualMediaType matches either images or videos, preferring images where a message contains both.
+	visualMediaType = mediaType{
+		name:                "image or video",
+		contentTypePrefixes: []string{"image/", "video/"},
+		urlFromEmbed:        visualURLFromEmbed,
+	}
 )
 
-type ImageOperation[K ImageOperationArgs] func(*imagick.MagickWand, K) ([]*imagick.MagickWand, error)
+// matchesContentType reports whether a MIME type is one of the types of media represented by this mediaType.
+func (kind mediaType) matchesContentType(contentType string) bool {
+	for _, prefix := range kind.contentTypePrefixes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type ImageOperation[K ImageOperationArgs] func(context.Context, *imagick.MagickWand, K) ([]*imagick.MagickWand, error)
 
 type OperationContext struct {
 	Session     *discordgo.Session
 	Message     *discordgo.MessageCreate
 	Interaction *discordgo.InteractionCreate
 	deferred    bool
+	jobCtx      context.Context
+
+	// responded is set once a result has been sent in response to an interaction, after which any further results
+	// and errors are sent as followups so that they don't replace it.
+	responded atomic.Bool
+
+	// commandName, sourceURL and rerun are set when an operation is re-run from the buttons on a previous result.
+	commandName string
+	sourceURL   string
+	rerun       rerunFunc
 }
 
 func NewOperationContextFromMessage(session *discordgo.Session, message *discordgo.MessageCreate) *OperationContext {
@@ -84,6 +119,52 @@ func (ctx *OperationContext) GetUserID() string {
 	return ""
 }
 
+func (ctx *OperationContext) GetGuildID() string {
+	if ctx.Message != nil {
+		return ctx.Message.GuildID
+	} else if ctx.Interaction != nil {
+		return ctx.Interaction.GuildID
+	}
+	return ""
+}
+
+// GetCommandName returns the name of the command that was invoked, resolving any aliases.
+func (ctx *OperationContext) GetCommandName() string {
+	if ctx.commandName != "" {
+		return ctx.commandName
+	}
+
+	var name string
+	if ctx.Message != nil {
+		content := ctx.Message.Content
+		for _, prefix := range Instance.config.Prefixes {
+			if strings.HasPrefix(content, prefix) {
+				content = strings.TrimPrefix(content, prefix)
+				break
+			}
+		}
+		if fields := strings.Fields(content); len(fields) > 0 {
+			name = strings.ToLower(fields[0])
+		}
+	} else if ctx.Interaction != nil && ctx.Interaction.Type == discordgo.InteractionApplicationCommand {
+		name = slashCommandName(ctx.Interaction.ApplicationCommandData())
+	}
+
+	if canonical, ok := commandNames[name]; ok {
+		return canonical
+	}
+	return name
+}
+
+// Context returns the context the operation is running under.
+// It is cancelled when the requester cancels the operation or its timeout elapses.
+func (ctx *OperationContext) Context() context.Context {
+	if ctx.jobCtx != nil {
+		return ctx.jobCtx
+	}
+	return context.Background()
+}
+
 func (ctx *OperationContext) GetChannelID() string {
 	if ctx.Message != nil {
 		return ctx.Message.ChannelID
@@ -131,45 +212,98 @@ func (ctx *OperationContext) SendText(content string) error {
 
 // SendEmbed sends an embed message.
 func (ctx *OperationContext) SendEmbed(embed *discordgo.MessageEmbed) error {
+	return ctx.sendEmbedWithComponents(embed, nil)
+}
+
+// sendEmbedWithComponents sends an embed message along with message components.
+func (ctx *OperationContext) sendEmbedWithComponents(
+	embed *discordgo.MessageEmbed,
+	components []discordgo.MessageComponent,
+) error {
 	if ctx.Message != nil {
-		_, err := ctx.Session.ChannelMessageSendEmbed(ctx.Message.ChannelID, embed)
+		_, err := ctx.Session.ChannelMessageSendComplex(ctx.Message.ChannelID, &discordgo.MessageSend{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+		})
 		return err
 	}
 	if ctx.deferred {
 		_, err := ctx.Session.InteractionResponseEdit(ctx.Interaction.Interaction, &discordgo.WebhookEdit{
-			Embeds: &[]*discordgo.MessageEmbed{embed},
+			Embeds:     &[]*discordgo.MessageEmbed{embed},
+			Components: &components,
 		})
 		return err
 	}
 	return ctx.Session.InteractionRespond(ctx.Interaction.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Embeds: []*discordgo.MessageEmbed{embed},
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
 		},
 	})
 }
 
 // SendFiles sends one or more file attachments.
 func (ctx *OperationContext) SendFiles(files []*discordgo.File) error {
+	return ctx.SendFilesWithContent("", files)
+}
+
+// SendFilesWithContent sends one or more file attachments, accompanied by a text message.
+func (ctx *OperationContext) SendFilesWithContent(content string, files []*discordgo.File) error {
+	_, err := ctx.sendFilesWithComponents(content, files, nil)
+	return err
+}
+
+// sendFilesWithComponents sends file attachments along with a text message and message components,
+// returning the message that was sent.
+func (ctx *OperationContext) sendFilesWithComponents(
+	content string,
+	files []*discordgo.File,
+	components []discordgo.MessageComponent,
+) (*discordgo.Message, error) {
 	if ctx.Message != nil {
-		_, err := ctx.Session.ChannelMessageSendComplex(ctx.Message.ChannelID, &discordgo.MessageSend{
-			Reference: ctx.Message.Reference(),
-			Files:     files,
+		return ctx.Session.ChannelMessageSendComplex(ctx.Message.ChannelID, &discordgo.MessageSend{
+			Content:    content,
+			Reference:  ctx.Message.Reference(),
+			Files:      files,
+			Components: components,
+		})
+	}
+	if ctx.responded.Load() {
+		// The response already holds an earlier result, so further results are sent alongside it.
+		return ctx.Session.FollowupMessageCreate(ctx.Interaction.Interaction, true, &discordgo.WebhookParams{
+			Content:    content,
+			Files:      files,
+			Components: components,
 		})
-		return err
 	}
 	if ctx.deferred {
-		_, err := ctx.Session.InteractionResponseEdit(ctx.Interaction.Interaction, &discordgo.WebhookEdit{
-			Files: files,
+		message, err := ctx.Session.InteractionResponseEdit(ctx.Interaction.Interaction, &discordgo.WebhookEdit{
+			Content:    &content,
+			Files:      files,
+			Components: &components,
+			// Replace any preview attached by a progress update.
+			Attachments: &[]*discordgo.MessageAttachment{},
 		})
-		return err
+		if err != nil {
+			return nil, err
+		}
+		ctx.responded.Store(true)
+		return message, nil
 	}
-	return ctx.Session.InteractionRespond(ctx.Interaction.Interaction, &discordgo.InteractionResponse{
+	err := ctx.Session.InteractionRespond(ctx.Interaction.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Files: files,
+			Content:    content,
+			Files:      files,
+			Components: components,
 		},
 	})
+	if err != nil {
+		return nil, err
+	}
+	ctx.responded.Store(true)
+	return ctx.Session.InteractionResponse(ctx.Interaction.Interaction)
 }
 
 func (ctx *OperationContext) findMediaURL(kind mediaType) (string, error) {
@@ -222,31 +356,29 @@ func Schedule(what func(), delay time.Duration) chan bool {
 	return stop
 }
 
-func mediaURLFromComponent(component discordgo.MessageComponent) string {
+// mediaURLsFromComponent returns the URL of every piece of media in a component, including each item of a gallery.
+func mediaURLsFromComponent(component discordgo.MessageComponent) []string {
 	switch c := component.(type) {
 	case *discordgo.MediaGallery:
-		if len(c.Items) == 0 {
-			return ""
+		urls := make([]string, 0, len(c.Items))
+		for _, item := range c.Items {
+			urls = append(urls, item.Media.URL)
 		}
 
-		return c.Items[0].Media.URL
+		return urls
 	case *discordgo.Container:
+		var urls []string
 		for _, child := range c.Components {
-			url := mediaURLFromComponent(child)
-			if url != "" {
-				return url
-			}
+			urls = append(urls, mediaURLsFromComponent(child)...)
 		}
 
-		return ""
+		return urls
 	default:
-		return ""
+		return nil
 	}
 }
 
 func mediaURLFromContent(content string, kind mediaType) string {
-	contentTypePrefix := kind.name + "/"
-
 	for _, candidate := range messageURLRegex.FindAllString(content, -1) {
 		candidate = strings.TrimRight(candidate, ".,!?;:)]}")
 		parsedURL, err := url.Parse(candidate)
@@ -255,35 +387,62 @@ func mediaURLFromContent(content string, kind mediaType) string {
 		}
 
 		contentType := mime.TypeByExtension(strings.ToLower(path.Ext(parsedURL.Path)))
-		if strings.HasPrefix(contentType, contentTypePrefix) {
+		if kind.matchesContentType(contentType) {
 			return candidate
 		}
 	}
 
+	// Links to pages on known GIF sites are followed through to their media when downloaded.
+	if Instance.pages != nil {
+		for _, candidate := range messageURLRegex.FindAllString(content, -1) {
+			candidate = strings.TrimRight(candidate, ".,!?;:)]}")
+			if Instance.pages.isKnownPage(candidate) {
+				return candidate
+			}
+		}
+	}
+
 	return ""
 }
 
-func mediaURLFromMessage(m *discordgo.Message, kind mediaType) string {
-	for _, embed := range m.Embeds {
-		if url := kind.urlFromEmbed(embed); url != "" {
-			return url
+// mediaURLsFromMessage returns every piece of media of the given kind in a message, in the order they are picked
+// from: embeds, then attachments, then components, then stickers, then links in the message's content.
+func mediaURLsFromMessage(m *discordgo.Message, kind mediaType) []string {
+	var urls []string
+	add := func(url string) {
+		if url != "" && !slices.Contains(urls, url) {
+			urls = append(urls, url)
 		}
 	}
 
+	for _, embed := range m.Embeds {
+		add(kind.urlFromEmbed(embed))
+	}
+
 	for _, attachment := range m.Attachments {
 		if attachmentMatchesMediaType(attachment, kind) {
-			return attachment.URL
+			add(attachment.URL)
 		}
 	}
 
 	for _, component := range m.Components {
-		if url := mediaURLFromComponent(component); url != "" {
-			return url
+		for _, url := range mediaURLsFromComponent(component) {
+			add(url)
 		}
 	}
 
-	if url := mediaURLFromContent(m.Content, kind); url != "" {
-		return url
+	for _, stickerURL := range stickerURLs(m, kind) {
+		add(stickerURL)
+	}
+
+	add(mediaURLFromContent(m.Content, kind))
+
+	return urls
+}
+
+func mediaURLFromMessage(m *discordgo.Message, kind mediaType) string {
+	if urls := mediaURLsFromMessage(m, kind); len(urls) > 0 {
+		return urls[0]
 	}
 
 	return ""
@@ -311,15 +470,20 @@ func videoURLFromEmbed(embed *discordgo.MessageEmbed) string {
 	return ""
 }
 
-func attachmentMatchesMediaType(attachment *discordgo.MessageAttachment, kind mediaType) bool {
-	contentTypePrefix := kind.name + "/"
+func visualURLFromEmbed(embed *discordgo.MessageEmbed) string {
+	if url := imageURLFromEmbed(embed); url != "" {
+		return url
+	}
+	return videoURLFromEmbed(embed)
+}
 
-	if strings.HasPrefix(attachment.ContentType, contentTypePrefix) {
+func attachmentMatchesMediaType(attachment *discordgo.MessageAttachment, kind mediaType) bool {
+	if kind.matchesContentType(attachment.ContentType) {
 		return true
 	}
 
 	contentType := mime.TypeByExtension(strings.ToLower(path.Ext(attachment.Filename)))
-	return strings.HasPrefix(contentType, contentTypePrefix)
+	return kind.matchesContentType(contentType)
 }
 
 func findMediaURLFromMessage(m *discordgo.MessageCreate, kind mediaType) (string, error) {
@@ -342,6 +506,13 @@ func findMediaURLInChannel(s *discordgo.Session, channelID string, beforeID stri
 		return "", fmt.Errorf("error retrieving message history: %w", err)
 	}
 
+	// Directly after one of our own results, follow on from it rather than whatever else is nearby.
+	if len(messages) > 0 {
+		if mediaURL, ok := channelHistories.currentIfLatest(channelID, messages[0].ID); ok {
+			return mediaURL, nil
+		}
+	}
+
 	for _, message := range messages {
 		if mediaURL := mediaURLFromMessage(message, kind); mediaURL != "" {
 			return mediaURL, nil
@@ -357,21 +528,50 @@ func closeBody(body io.Closer, message string) {
 }
 
 // DownloadImage downloads an image from a given URL, returning the resulting bytes.
-func DownloadImage(url string) ([]byte, error) {
+func DownloadImage(ctx context.Context, url string) ([]byte, error) {
 	log.Debug().Str("url", url).Msg("Downloading image")
-	resp, err := http.Get(url)
+	var resp *http.Response
+	var err error
+	if Instance.pages != nil {
+		resp, err = Instance.pages.Open(ctx, url)
+	} else {
+		resp, err = Instance.downloader.Get(ctx, url)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error downloading image: %w", err)
 	}
 	defer closeBody(resp.Body, "Error closing image response body")
 
+	return readDownload(resp)
+}
+
+// readDownload reads the body of a download, refusing anything larger than the configured maximum download size.
+func readDownload(resp *http.Response) ([]byte, error) {
+	maxSize := Instance.config.MaxDownloadSize
+	if maxSize != 0 && resp.ContentLength > maxSize {
+		return nil, newUserError(
+			"That file is too large to download (%s, the limit is %s).",
+			formatByteSize(resp.ContentLength),
+			formatByteSize(maxSize),
+		)
+	}
+
 	buffer := new(bytes.Buffer)
 
-	_, err = io.Copy(buffer, resp.Body)
+	body := io.Reader(resp.Body)
+	if maxSize != 0 {
+		body = io.LimitReader(resp.Body, maxSize+1)
+	}
+
+	_, err := io.Copy(buffer, body)
 	if err != nil {
 		return nil, fmt.Errorf("error copying image to buffer: %w", err)
 	}
 
+	if maxSize != 0 && int64(buffer.Len()) > maxSize {
+		return nil, newUserError("That file is too large to download (the limit is %s).", formatByteSize(maxSize))
+	}
+
 	return buffer.Bytes(), nil
 }
 
@@ -382,83 +582,313 @@ func MakeImageOpTextCommand[K ImageOperationArgs](operation ImageOperation[K]) f
 	}
 }
 
-func MakeImageOpSlashCommand[K ImageOperationArgs](
-	operation ImageOperation[K],
-) func(*discordgo.Session, *discordgo.InteractionCreate, K) {
-	return func(session *discordgo.Session, interaction *discordgo.InteractionCreate, args K) {
-		PrepareAndInvokeOperation(NewOperationContextFromInteraction(session, interaction), args, operation)
-	}
+func MakeImageOpSlashCommand[K ImageOperationArgs](operation ImageOperation[K]) any {
+	return makeAttachmentSlashCommand(func(ctx *OperationContext, args K) {
+		PrepareAndInvokeOperation(ctx, args, operation)
+	})
+}
+
+// imageAttachmentField is the option added to the slash commands of image operations for uploading an image directly.
+// Like every other optional argument it's given a default, leaving it nil when no image is uploaded.
+var imageAttachmentField = reflect.StructField{
+	Name: "Image",
+	Type: reflect.TypeFor[*discordgo.MessageAttachment](),
+	Tag:  `default:"" description:"Image to process. Takes precedence over the URL and searching for an image."`,
+}
+
+// makeAttachmentSlashCommand creates a slash command handler whose arguments are those of an image operation with an
+// optional attachment added, which is used as the operation's input when one is uploaded.
+// The arguments are an unnamed struct type built at runtime, which slashOptions and slashArgs handle like any other.
+func makeAttachmentSlashCommand[K ImageOperationArgs](handler func(*OperationContext, K)) any {
+	argsType := reflect.TypeFor[K]()
+	fields := make([]reflect.StructField, 0, argsType.NumField()+1)
+	for index := 0; index < argsType.NumField(); index++ {
+		fields = append(fields, argsType.Field(index))
+	}
+	slashArgsType := reflect.StructOf(append(fields, imageAttachmentField))
+
+	handlerType := reflect.FuncOf(
+		[]reflect.Type{
+			reflect.TypeFor[*discordgo.Session](),
+			reflect.TypeFor[*discordgo.InteractionCreate](),
+			slashArgsType,
+		},
+		nil,
+		false,
+	)
+
+	return reflect.MakeFunc(handlerType, func(in []reflect.Value) []reflect.Value {
+		session := in[0].Interface().(*discordgo.Session)
+		interaction := in[1].Interface().(*discordgo.InteractionCreate)
+		slashArgs := in[2]
+
+		args := reflect.New(argsType).Elem()
+		for index := 0; index < argsType.NumField(); index++ {
+			args.Field(index).Set(slashArgs.Field(index))
+		}
+
+		ctx := NewOperationContextFromInteraction(session, interaction)
+		if attachment := slashArgs.Field(argsType.NumField()).Interface().(*discordgo.MessageAttachment); attachment != nil {
+			ctx.sourceURL = attachment.URL
+		}
+
+		handler(ctx, args.Interface().(K))
+		return nil
+	}).Interface()
 }
 
 // AIImageOperation is like ImageOperation but also receives AISessionMetadata for session tracking and seed management.
 type AIImageOperation[K ImageOperationArgs] func(
+	context.Context,
 	*imagick.MagickWand,
 	K,
 	AISessionMetadata,
 ) ([]*imagick.MagickWand, error)
 
-// MakeAIImageOpTextCommand creates a Parsley command handler for an AIImageOperation,
-// building full AISessionMetadata (with seed, session ID, user ID) from the OperationContext.
+// newAISessionMetadata builds the AISessionMetadata for an operation, using the seed from its arguments if given.
+func newAISessionMetadata(ctx *OperationContext, args ImageOperationArgs) AISessionMetadata {
+	seed := rand.Int()
+	if seeded, ok := args.(SeededOperationArgs); ok && seeded.GetSeed() != 0 {
+		seed = seeded.GetSeed()
+	}
+
+	return AISessionMetadata{
+		Seed:      seed,
+		SessionID: ctx.GetSourceID(),
+		UserID:    ctx.GetUserID(),
+	}
+}
+
+// MakeAIImageOpTextCommand creates a Parsley command handler for an AIImageOperation.
 func MakeAIImageOpTextCommand[K ImageOperationArgs](operation AIImageOperation[K]) func(*discordgo.MessageCreate, K) {
 	return func(message *discordgo.MessageCreate, args K) {
-		ctx := NewOperationContextFromMessage(Instance.session, message)
-		metadata := AISessionMetadata{
-			Seed:      rand.Int(),
-			SessionID: ctx.GetSourceID(),
-			UserID:    ctx.GetUserID(),
-		}
-		PrepareAndInvokeOperation(ctx, args, func(wand *imagick.MagickWand, args K) ([]*imagick.MagickWand, error) {
-			return operation(wand, args, metadata)
-		})
+		PrepareAndInvokeAIOperation(NewOperationContextFromMessage(Instance.session, message), args, operation)
 	}
 }
 
-// MakeAIImageOpSlashCommand creates a slash command handler for an AIImageOperation,
+// MakeAIImageOpSlashCommand creates a slash command handler for an AIImageOperation.
+func MakeAIImageOpSlashCommand[K ImageOperationArgs](operation AIImageOperation[K]) any {
+	return makeAttachmentSlashCommand(func(ctx *OperationContext, args K) {
+		PrepareAndInvokeAIOperation(ctx, args, operation)
+	})
+}
+
+// PrepareAndInvokeAIOperation invokes an AIImageOperation,
 // building full AISessionMetadata (with seed, session ID, user ID) from the OperationContext.
-func MakeAIImageOpSlashCommand[K ImageOperationArgs](
-	operation AIImageOperation[K],
-) func(*discordgo.Session, *discordgo.InteractionCreate, K) {
-	return func(session *discordgo.Session, interaction *discordgo.InteractionCreate, args K) {
-		ctx := NewOperationContextFromInteraction(session, interaction)
-		metadata := AISessionMetadata{
-			Seed:      rand.Int(),
-			SessionID: ctx.GetSourceID(),
-			UserID:    ctx.GetUserID(),
+func PrepareAndInvokeAIOperation[K ImageOperationArgs](ctx *OperationContext, args K, operation AIImageOperation[K]) {
+	// Re-runs get fresh metadata, so that they pick a new seed unless one was given.
+	ctx.rerun = func(ctx *OperationContext, args ImageOperationArgs) {
+		PrepareAndInvokeAIOperation(ctx, args.(K), operation)
+	}
+
+	metadata := newAISessionMetadata(ctx, args)
+	PrepareAndInvokeOperation(
+		ctx,
+		args,
+		func(opCtx context.Context, wand *imagick.MagickWand, args K) ([]*imagick.MagickWand, error) {
+			return operation(opCtx, wand, args, metadata)
+		},
+	)
+}
+
+// frameTiming holds the animation properties of a single input frame.
+type frameTiming struct {
+	delay          uint
+	ticksPerSecond uint
+	dispose        imagick.DisposeType
+}
+
+// getFrameTimings reads the timing of each frame of an image, prior to it being coalesced.
+func getFrameTimings(wand *imagick.MagickWand) []frameTiming {
+	timings := make([]frameTiming, wand.GetNumberImages())
+	for i := range timings {
+		wand.SetIteratorIndex(i)
+		timings[i] = frameTiming{
+			delay:          wand.GetImageDelay(),
+			ticksPerSecond: wand.GetImageTicksPerSecond(),
+			dispose:        wand.GetImageDispose(),
+		}
+	}
+	wand.ResetIterator()
+	return timings
+}
+
+// applyFrameTiming carries the timing of an input frame through to the frames an operation produced from it.
+// When an operation produces several frames from one input frame, they split the original frame's delay between them.
+func applyFrameTiming(frames []*imagick.MagickWand, timing frameTiming, iterations uint) error {
+	delays := splitFrameDelay(timing.delay, len(frames))
+	for index, frame := range frames {
+		if err := frame.SetImageTicksPerSecond(int(timing.ticksPerSecond)); err != nil {
+			return fmt.Errorf("error setting ticks per second: %w", err)
+		}
+		if err := frame.SetImageDelay(delays[index]); err != nil {
+			return fmt.Errorf("error setting delay: %w", err)
+		}
+		if err := frame.SetImageDispose(timing.dispose); err != nil {
+			return fmt.Errorf("error setting disposal: %w", err)
+		}
+		if err := frame.SetImageIterations(iterations); err != nil {
+			return fmt.Errorf("error setting iterations: %w", err)
+		}
+	}
+	return nil
+}
+
+// splitFrameDelay divides a frame's delay between the given number of frames, spreading any remainder over the
+// first frames so that the total is unchanged.
+func splitFrameDelay(delay uint, count int) []uint {
+	delays := make([]uint, count)
+	for index := range delays {
+		delays[index] = delay / uint(count)
+		if uint(index) < delay%uint(count) {
+			delays[index]++
 		}
-		PrepareAndInvokeOperation(ctx, args, func(wand *imagick.MagickWand, args K) ([]*imagick.MagickWand, error) {
-			return operation(wand, args, metadata)
-		})
 	}
+	return delays
 }
 
 // PrepareAndInvokeOperation automatically handles invoking a given ImageOperation and returning the finished results.
 func PrepareAndInvokeOperation[K ImageOperationArgs](ctx *OperationContext, args K, operation ImageOperation[K]) {
+	if err := validateArgs(args); err != nil {
+		ctx.ReportError(err)
+		return
+	}
+
 	defer TypingIndicatorForContext(ctx)()
 
+	if ctx.rerun == nil {
+		ctx.rerun = func(ctx *OperationContext, args ImageOperationArgs) {
+			PrepareAndInvokeOperation(ctx, args.(K), operation)
+		}
+	}
+
 	if err := ctx.DeferResponse(); err != nil {
 		log.Error().Err(err).Msg("Failed to defer response")
 		return
 	}
 
+	finish, err := ctx.StartJob()
+	if err != nil {
+		ctx.ReportError(err)
+		return
+	}
+	defer finish()
+
+	if err := invokeOperation(ctx, args, operation); err != nil {
+		ctx.ReportError(err)
+	}
+}
+
+// invokeOperation locates the image for an operation and runs the operation on it, or on each piece of media in a
+// message when asked to process all of them.
+func invokeOperation[K ImageOperationArgs](ctx *OperationContext, args K, operation ImageOperation[K]) error {
 	imageUrl := args.GetImageURL()
-	if imageUrl == "" {
-		var err error
-		imageUrl, err = ctx.findMediaURL(imageMediaType)
+	if ctx.sourceURL != "" {
+		imageUrl = ctx.sourceURL
+	}
+
+	if imageUrl == mediaPickAll {
+		mediaURLs, err := ctx.findMessageMedia(visualMediaType)
 		if err != nil {
-			log.Error().Err(err).Msg("Error while attempting to find image to process")
-			return
+			return withUserMessage(err, "I couldn't find a message with images or videos to process.")
+		}
+		if len(mediaURLs) > maxPickedMedia {
+			mediaURLs = mediaURLs[:maxPickedMedia]
+		}
+
+		for _, mediaURL := range mediaURLs {
+			if err := runOperation(ctx, mediaURL, args, operation); err != nil {
+				if ctx.Context().Err() != nil {
+					return err
+				}
+				ctx.ReportError(err)
+			}
 		}
+		return nil
 	}
 
-	srcBytes, err := DownloadImage(imageUrl)
+	imageUrl, err := ctx.resolveMediaURL(imageUrl, visualMediaType)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to download image to process")
-		return
+		return withUserMessage(
+			err,
+			"I couldn't find an image or video to process. Attach one, reply to a message with one, or provide a URL.",
+		)
+	}
+
+	return runOperation(ctx, imageUrl, args, operation)
+}
+
+// runOperation downloads an image, runs an operation on each of its frames, and uploads the result.
+func runOperation[K ImageOperationArgs](
+	ctx *OperationContext,
+	imageUrl string,
+	args K,
+	operation ImageOperation[K],
+) error {
+	srcBytes, err := DownloadImage(ctx.Context(), imageUrl)
+	if err != nil {
+		return withUserMessage(err, "I couldn't download that file.")
+	}
+
+	uploadLimit := ctx.GetUploadLimit()
+	outputFormat := animatedOutputFormat(ctx.GetCommandName())
+
+	var cacheKey string
+	if Instance.cache.enabled() && isCacheable(args) {
+		cacheKey, err = resultCacheKey(srcBytes, ctx.GetCommandName(), args, uploadLimit, outputFormat)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to compute cache key")
+		} else if result, ok := Instance.cache.Get(cacheKey); ok {
+			log.Debug().Str("key", cacheKey).Msg("Returning cached result")
+			return sendResult(ctx, imageUrl, args, result)
+		}
 	}
 
 	parsedUrl, _ := url.Parse(imageUrl)
 	filename := path.Base(parsedUrl.Path)
 
+	run := func(opCtx context.Context, frame *imagick.MagickWand) ([]*imagick.MagickWand, error) {
+		return operation(opCtx, frame, args)
+	}
+	workers := frameWorkerCount(args)
+
+	var result *cachedResult
+	if isVideo(srcBytes, filename) {
+		result, err = processVideo(ctx.Context(), srcBytes, filename, run, workers, uploadLimit, outputFormat)
+	} else {
+		result, err = processImage(ctx.Context(), srcBytes, filename, run, workers, uploadLimit, outputFormat)
+	}
+	if err != nil {
+		return err
+	}
+
+	if cacheKey != "" {
+		Instance.cache.Put(cacheKey, result)
+	}
+
+	log.Debug().Msg("Image processed, uploading result")
+	return sendResult(ctx, imageUrl, args, result)
+}
+
+// frameOperation runs an operation on a single frame, with its arguments already bound.
+type frameOperation func(context.Context, *imagick.MagickWand) ([]*imagick.MagickWand, error)
+
+// decodedImage holds the coalesced frames of an input image, along with the timing of each frame.
+type decodedImage struct {
+	frames     []*imagick.MagickWand
+	timings    []frameTiming
+	iterations uint
+}
+
+// decodeImage checks an image against the input limits and splits it into its coalesced frames.
+func decodeImage(srcBytes []byte, filename string) (*decodedImage, error) {
+	filename = decodeFilename(srcBytes, filename)
+
+	err := checkImageLimits(srcBytes, filename)
+	if err != nil {
+		return nil, withUserMessage(err, "I couldn't read that image. It may be corrupt, or in a format I don't support.")
+	}
+
 	input := imagick.NewMagickWand()
 	err = input.SetFilename(filename)
 	if err != nil {
@@ -466,98 +896,125 @@ func PrepareAndInvokeOperation[K ImageOperationArgs](ctx *OperationContext, args
 	}
 	err = input.ReadImageBlob(srcBytes)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to read image")
-		return
+		return nil, withUserMessage(
+			fmt.Errorf("error reading image: %w", err),
+			"I couldn't read that image. It may be corrupt, or in a format I don't support.",
+		)
 	}
-	input = input.CoalesceImages()
-
-	var resultFrames []*imagick.MagickWand
-	for i := 0; i < int(input.GetNumberImages()); i++ {
-		input.SetIteratorIndex(i)
-		inputFrame := input.GetImage().Clone()
-		log.Debug().Int("frame", i).Msg("Beginning processing frame")
-		output, err := operation(inputFrame, args)
+	if isAPNG(srcBytes) {
+		coalesced, err := coalesceAPNG(input)
 		if err != nil {
-			log.Error().Err(err).Msg("Failed to process image")
-			return
+			return nil, err
 		}
-		resultFrames = append(resultFrames, output...)
+		input.Destroy()
+		input = coalesced
 	}
 
-	resultImage := imagick.NewMagickWand()
-	for index, frame := range resultFrames {
-		log.Debug().Int("frame", index).Msg("Adding frame to result image")
-		err := resultImage.AddImage(frame)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to add frame")
-			return
-		}
+	decoded := &decodedImage{
+		timings:    getFrameTimings(input),
+		iterations: input.GetImageIterations(),
 	}
+	input = input.CoalesceImages()
 
+	decoded.frames = make([]*imagick.MagickWand, input.GetNumberImages())
+	for i := range decoded.frames {
+		input.SetIteratorIndex(i)
+		decoded.frames[i] = input.GetImage().Clone()
+	}
 	input.ResetIterator()
-	resultImage.ResetIterator()
 
-	log.Debug().Msg("Setting image format")
-	if len(resultFrames) > 1 {
-		err := resultImage.SetImageFormat("GIF")
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to set result format")
-			return
-		}
-		err = resultImage.SetImageDelay(input.GetImageDelay())
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to set framerate")
-			return
-		}
-	} else {
-		err := resultImage.SetImageFormat("PNG")
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to set result format")
-			return
-		}
+	return decoded, nil
+}
+
+// processImage decodes an image, runs an operation on each of its frames, and encodes the result.
+func processImage(
+	ctx context.Context,
+	srcBytes []byte,
+	filename string,
+	operation frameOperation,
+	workers int,
+	uploadLimit int64,
+	outputFormat string,
+) (*cachedResult, error) {
+	input, err := decodeImage(srcBytes, filename)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Debug().Msg("Repaging image")
-	err = resultImage.ResetImagePage("0x0+0+0")
+	outputs, err := processFrames(
+		ctx,
+		input.frames,
+		workers,
+		func(opCtx context.Context, index int, frame *imagick.MagickWand) ([]*imagick.MagickWand, error) {
+			output, err := operation(opCtx, frame)
+			if err != nil {
+				return nil, err
+			}
+			if err := applyFrameTiming(output, input.timings[index], input.iterations); err != nil {
+				return nil, fmt.Errorf("error applying frame timing: %w", err)
+			}
+			return output, nil
+		},
+	)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to repage image")
+		return nil, err
 	}
 
-	log.Debug().Msg("Deconstructing image")
-	resultImage = resultImage.DeconstructImages()
-	destBuffer := new(bytes.Buffer)
+	return encodeResultFrames(ctx, outputs, outputFormat, uploadLimit)
+}
 
-	imageBlob, err := resultImage.GetImagesBlob()
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to get image blob")
-		return
+// encodeResultFrames encodes the frames an operation produced, as a still image or in the animated output format.
+func encodeResultFrames(
+	ctx context.Context,
+	outputs [][]*imagick.MagickWand,
+	outputFormat string,
+	uploadLimit int64,
+) (*cachedResult, error) {
+	var resultFrames []*imagick.MagickWand
+	for _, output := range outputs {
+		resultFrames = append(resultFrames, output...)
 	}
 
-	log.Debug().Msg("Writing output image")
-	_, err = destBuffer.Write(imageBlob)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to write image")
-		return
+	if len(resultFrames) > 1 {
+		return encodeAnimatedResult(ctx, resultFrames, outputFormat, uploadLimit, nil)
 	}
 
-	originalFileName := path.Base(imageUrl)
+	result, err := encodeImageResult(resultFrames, pngOutputFormat, uploadLimit)
+	if errors.Is(err, errResultTooLarge) {
+		return nil, &UserError{Message: uploadLimitError(uploadLimit), Err: err}
+	}
+	return result, err
+}
+
+// sendResult uploads the result of an operation, naming it after the image it was produced from,
+// and attaches buttons for following up on it.
+func sendResult(ctx *OperationContext, imageUrl string, args ImageOperationArgs, result *cachedResult) error {
+	parsedUrl, _ := url.Parse(imageUrl)
+	originalFileName := path.Base(parsedUrl.Path)
 	originalFileNameNoExt := strings.TrimSuffix(originalFileName, path.Ext(originalFileName))
 
-	log.Debug().Msg("Image processed, uploading result")
+	state := newResultState(ctx, args, imageUrl)
 
-	resultFileName := fmt.Sprintf("%s.%s", originalFileNameNoExt, strings.ToLower(resultImage.GetImageFormat()))
-	err = ctx.SendFiles([]*discordgo.File{
-		{
-			Name:   resultFileName,
-			Reader: destBuffer,
+	resultFileName := fmt.Sprintf("%s.%s", originalFileNameNoExt, result.Format)
+	message, err := ctx.sendFilesWithComponents(
+		result.Content,
+		[]*discordgo.File{
+			{
+				Name:   resultFileName,
+				Reader: bytes.NewReader(result.Blob),
+			},
 		},
-	})
+		state.components(),
+	)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to send image")
-		if sendErr := ctx.SendText(fmt.Sprintf("Failed to send resulting image: `%s`", err.Error())); sendErr != nil {
-			log.Error().Err(sendErr).Msg("Failed to send error message")
-		}
+		return withUserMessage(fmt.Errorf("error sending image: %w", err), "I couldn't upload the result.")
 	}
+
+	state.save(message)
+	if outputURL := attachmentURL(message); outputURL != "" {
+		channelHistories.record(ctx.GetChannelID(), imageUrl, outputURL, message.ID)
+	}
+	return nil
 }
 
 // FindTransparentOpeningRect finds the bounding rectangle of the transparent region in an image.
@@ -623,7 +1080,7 @@ type OverlayOptions struct {
 
 // FrameArgs are the arguments for frame commands.
 type FrameArgs struct {
-	ImageURL string `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL string `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 }
 
 func (args FrameArgs) GetImageURL() string {
@@ -710,7 +1167,7 @@ func FrameImage(
 // MakeImageFrameOp creates an ImageOperation that places the input image into the transparent
 // opening of a frame image, auto-detecting the opening position and size.
 func MakeImageFrameOp(frameBytes []byte, options FrameOptions) ImageOperation[FrameArgs] {
-	return func(wand *imagick.MagickWand, args FrameArgs) ([]*imagick.MagickWand, error) {
+	return func(_ context.Context, wand *imagick.MagickWand, args FrameArgs) ([]*imagick.MagickWand, error) {
 		frame := imagick.NewMagickWand()
 		if err := frame.ReadImageBlob(frameBytes); err != nil {
 			return nil, fmt.Errorf("error reading frame: %w", err)
@@ -779,7 +1236,7 @@ func OverlayImage(wand *imagick.MagickWand, overlay []byte, options OverlayOptio
 }
 
 type OverlayImageArgs struct {
-	ImageURL string `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL string `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 	HFlip    bool   `default:"false" description:"Flip the overlay horizontally."`
 	VFlip    bool   `default:"false" description:"Flip the overlay vertically."`
 }
@@ -789,7 +1246,7 @@ func (args OverlayImageArgs) GetImageURL() string {
 }
 
 func MakeImageOverlayOp(overlayImage []byte, initialOptions OverlayOptions) ImageOperation[OverlayImageArgs] {
-	return func(wand *imagick.MagickWand, args OverlayImageArgs) ([]*imagick.MagickWand, error) {
+	return func(_ context.Context, wand *imagick.MagickWand, args OverlayImageArgs) ([]*imagick.MagickWand, error) {
 		newOptions := initialOptions
 
 		if args.HFlip {
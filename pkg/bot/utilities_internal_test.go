@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+func TestSplitFrameDelay(t *testing.T) {
+	tests := []struct {
+		name  string
+		delay uint
+		count int
+		want  []uint
+	}{
+		{name: "no frames", delay: 7, count: 0, want: []uint{}},
+		{name: "single frame", delay: 7, count: 1, want: []uint{7}},
+		{name: "even split", delay: 9, count: 3, want: []uint{3, 3, 3}},
+		{name: "remainder goes to first frames", delay: 7, count: 3, want: []uint{3, 2, 2}},
+		{name: "more frames than ticks", delay: 2, count: 5, want: []uint{1, 1, 0, 0, 0}},
+		{name: "zero delay", delay: 0, count: 4, want: []uint{0, 0, 0, 0}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := splitFrameDelay(test.delay, test.count)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("splitFrameDelay(%d, %d) = %v, want %v", test.delay, test.count, got, test.want)
+			}
+
+			var total uint
+			for _, delay := range got {
+				total += delay
+			}
+			if test.count > 0 && total != test.delay {
+				t.Errorf("delays sum to %d, want %d", total, test.delay)
+			}
+		})
+	}
+}
+
+// newTestFrame creates a small blank frame for tests.
+func newTestFrame(t *testing.T) *imagick.MagickWand {
+	t.Helper()
+
+	background := imagick.NewPixelWand()
+	defer background.Destroy()
+	background.SetColor("red")
+
+	wand := imagick.NewMagickWand()
+	t.Cleanup(wand.Destroy)
+	if err := wand.NewImage(2, 2, background); err != nil {
+		t.Fatalf("error creating test frame: %v", err)
+	}
+	return wand
+}
+
+func TestApplyFrameTiming(t *testing.T) {
+	timing := frameTiming{delay: 7, ticksPerSecond: 100, dispose: imagick.DISPOSE_BACKGROUND}
+
+	for _, count := range []int{0, 1, 3} {
+		frames := make([]*imagick.MagickWand, count)
+		for index := range frames {
+			frames[index] = newTestFrame(t)
+		}
+
+		if err := applyFrameTiming(frames, timing, 2); err != nil {
+			t.Fatalf("unexpected error applying timing to %d frames: %v", count, err)
+		}
+
+		want := splitFrameDelay(timing.delay, count)
+		for index, frame := range frames {
+			if got := frame.GetImageDelay(); got != want[index] {
+				t.Errorf("frame %d of %d has delay %d, want %d", index, count, got, want[index])
+			}
+			if got := frame.GetImageDispose(); got != timing.dispose {
+				t.Errorf("frame %d of %d has disposal %v, want %v", index, count, got, timing.dispose)
+			}
+			if got := frame.GetImageIterations(); got != 2 {
+				t.Errorf("frame %d of %d has %d iterations, want 2", index, count, got)
+			}
+		}
+	}
+}
+
+func TestMakeAttachmentSlashCommand(t *testing.T) {
+	var gotArgs MagikArgs
+	var gotSourceURL string
+	handler := reflect.ValueOf(makeAttachmentSlashCommand(func(ctx *OperationContext, args MagikArgs) {
+		gotArgs = args
+		gotSourceURL = ctx.sourceURL
+	}))
+	argsType := handler.Type().In(2)
+
+	options, err := slashOptions(argsType)
+	if err != nil {
+		t.Fatalf("unexpected error generating options: %v", err)
+	}
+	image := options[len(options)-1]
+	if image.Name != "image" || image.Type != discordgo.ApplicationCommandOptionAttachment || image.Required {
+		t.Errorf(
+			"last option is (%s, %v, required %v), want an optional image attachment",
+			image.Name, image.Type, image.Required,
+		)
+	}
+
+	interaction := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{}}
+	call := func(options []*discordgo.ApplicationCommandInteractionDataOption) {
+		t.Helper()
+		resolved := &discordgo.ApplicationCommandInteractionDataResolved{
+			Attachments: map[string]*discordgo.MessageAttachment{"7": {ID: "7", URL: "https://example.com/a.png"}},
+		}
+		args, err := slashArgs(argsType, options, resolved)
+		if err != nil {
+			t.Fatalf("unexpected error building arguments: %v", err)
+		}
+		handler.Call([]reflect.Value{reflect.ValueOf((*discordgo.Session)(nil)), reflect.ValueOf(interaction), args})
+	}
+
+	call([]*discordgo.ApplicationCommandInteractionDataOption{
+		{Name: "scale", Type: discordgo.ApplicationCommandOptionNumber, Value: 3.0},
+	})
+	if gotArgs.Scale != 3 || gotArgs.WidthMultiplier != 0.5 {
+		t.Errorf("got scale %v and width multiplier %v, want 3 and 0.5", gotArgs.Scale, gotArgs.WidthMultiplier)
+	}
+	if gotSourceURL != "" {
+		t.Errorf("got source URL %q without an attachment, want none", gotSourceURL)
+	}
+
+	call([]*discordgo.ApplicationCommandInteractionDataOption{
+		{Name: "image", Type: discordgo.ApplicationCommandOptionAttachment, Value: "7"},
+	})
+	if gotSourceURL != "https://example.com/a.png" {
+		t.Errorf("got source URL %q, want the attachment's URL", gotSourceURL)
+	}
+}
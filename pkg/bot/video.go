@@ -0,0 +1,492 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// videoTicksPerSecond is the timing resolution used for frames extracted from videos.
+const videoTicksPerSecond = 1000
+
+// imageFtypBrands are ISO base media file brands used by image formats rather than videos.
+var imageFtypBrands = map[string]bool{
+	"avif": true,
+	"avis": true,
+	"heic": true,
+	"heix": true,
+	"mif1": true,
+	"msf1": true,
+}
+
+// isVideo reports whether a downloaded file is a video, based on its content and falling back to its extension.
+func isVideo(blob []byte, filename string) bool {
+	if strings.HasPrefix(http.DetectContentType(blob), "video/") {
+		return true
+	}
+
+	// MP4 variants such as QuickTime aren't recognised by http.DetectContentType.
+	if len(blob) >= 12 && string(blob[4:8]) == "ftyp" {
+		return !imageFtypBrands[string(blob[8:12])]
+	}
+
+	return strings.HasPrefix(mime.TypeByExtension(strings.ToLower(path.Ext(filename))), "video/")
+}
+
+// runMediaCommand runs an external media tool such as ffmpeg, returning its output.
+func runMediaCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return nil, fmt.Errorf("%s failed: %s", name, message)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// videoInfo holds the properties of a video that are needed to process it.
+type videoInfo struct {
+	duration  float64
+	frameRate float64
+}
+
+// probeVideo reads the duration and frame rate of a video.
+func probeVideo(ctx context.Context, inputPath string) (videoInfo, error) {
+	output, err := runMediaCommand(
+		ctx,
+		"ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=avg_frame_rate:format=duration",
+		"-of", "json",
+		inputPath,
+	)
+	if err != nil {
+		return videoInfo{}, err
+	}
+
+	var probe struct {
+		Streams []struct {
+			AvgFrameRate string `json:"avg_frame_rate"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return videoInfo{}, fmt.Errorf("error parsing ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 {
+		return videoInfo{}, errors.New("no video stream found")
+	}
+
+	var info videoInfo
+	info.duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+
+	numerator, denominator, found := strings.Cut(probe.Streams[0].AvgFrameRate, "/")
+	num, _ := strconv.ParseFloat(numerator, 64)
+	den := 1.0
+	if found {
+		den, _ = strconv.ParseFloat(denominator, 64)
+	}
+	if den != 0 {
+		info.frameRate = num / den
+	}
+
+	return info, nil
+}
+
+// videoSampling decides the duration and frame rate to extract from a video, within the configured limits.
+func videoSampling(info videoInfo) (duration float64, frameRate float64, truncated bool) {
+	duration = Instance.config.VideoMaxDuration.Seconds()
+	if info.duration > 0 && info.duration <= duration {
+		duration = info.duration
+	} else if info.duration > duration {
+		truncated = true
+	}
+
+	frameRate = Instance.config.VideoMaxFps
+	if info.frameRate > 0 && info.frameRate < frameRate {
+		frameRate = info.frameRate
+	}
+
+	if maxFrames := float64(Instance.config.MaxInputFrames); maxFrames != 0 && frameRate*duration > maxFrames {
+		frameRate = maxFrames / duration
+	}
+
+	return duration, frameRate, truncated
+}
+
+// extractVideoFrames decodes the frames of a video into dir, reading them back as individual wands.
+func extractVideoFrames(
+	ctx context.Context,
+	inputPath string,
+	dir string,
+	duration float64,
+	frameRate float64,
+) ([]*imagick.MagickWand, error) {
+	maxDimension := Instance.config.VideoMaxDimension
+	filter := fmt.Sprintf(
+		"fps=%f,scale=w='min(%d,iw)':h='min(%d,ih)':force_original_aspect_ratio=decrease",
+		frameRate,
+		maxDimension,
+		maxDimension,
+	)
+
+	_, err := runMediaCommand(
+		ctx,
+		"ffmpeg",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-t", fmt.Sprintf("%.3f", duration),
+		"-i", inputPath,
+		"-vf", filter,
+		"-f", "image2",
+		filepath.Join(dir, "input_%06d.png"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "input_*.png"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing extracted frames: %w", err)
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no frames were extracted from the video")
+	}
+
+	frames := make([]*imagick.MagickWand, len(paths))
+	for index, framePath := range paths {
+		frame := imagick.NewMagickWand()
+		if err := frame.ReadImage(framePath); err != nil {
+			return nil, fmt.Errorf("error reading extracted frame: %w", err)
+		}
+		frames[index] = frame
+	}
+
+	return frames, nil
+}
+
+// videoOutputFormat returns the format the result of a video input is encoded to. Image formats can't carry the
+// audio track, so video inputs are always re-encoded as videos. Automatic mode only considers video formats when
+// there is audio.
+func videoOutputFormat(format string) string {
+	if _, ok := videoCodecs[format]; ok || format == outputFormatAuto {
+		return format
+	}
+	return outputFormatMP4
+}
+
+// defaultFrameDelay is the delay, in hundredths of a second, that frames without one are shown for, as browsers do
+// for GIFs.
+const defaultFrameDelay = 10
+
+// concatFrameDuration returns how many seconds a frame with the given delay is shown for.
+func concatFrameDuration(delay uint, ticksPerSecond uint) float64 {
+	if delay == 0 {
+		return defaultFrameDelay / 100.0
+	}
+	if ticksPerSecond == 0 {
+		ticksPerSecond = 100
+	}
+	return float64(delay) / float64(ticksPerSecond)
+}
+
+// writeConcatList writes the frames of a result into dir, along with an ffmpeg concat script that plays each
+// frame for its own duration.
+func writeConcatList(frames []*imagick.MagickWand, dir string) (string, error) {
+	var script strings.Builder
+	script.WriteString("ffconcat version 1.0\n")
+
+	var lastFrame string
+	for index, frame := range frames {
+		framePath := filepath.Join(dir, fmt.Sprintf("output_%06d.png", index))
+		if err := frame.SetImageFormat("PNG"); err != nil {
+			return "", fmt.Errorf("error setting frame format: %w", err)
+		}
+		if err := frame.WriteImage(framePath); err != nil {
+			return "", fmt.Errorf("error writing frame: %w", err)
+		}
+
+		frameDuration := concatFrameDuration(frame.GetImageDelay(), frame.GetImageTicksPerSecond())
+
+		_, _ = fmt.Fprintf(&script, "file '%s'\nduration %.4f\n", filepath.Base(framePath), frameDuration)
+		lastFrame = framePath
+	}
+	// The concat demuxer ignores the duration of the final entry, so the last frame is repeated to preserve it.
+	_, _ = fmt.Fprintf(&script, "file '%s'\n", filepath.Base(lastFrame))
+
+	listPath := filepath.Join(dir, "frames.txt")
+	if err := os.WriteFile(listPath, []byte(script.String()), 0o644); err != nil {
+		return "", fmt.Errorf("error writing concat list: %w", err)
+	}
+	return listPath, nil
+}
+
+// videoCodec describes how results are encoded to a video format.
+type videoCodec struct {
+	format string
+	args   []string
+	// qualitySteps are the CRF values tried, in order, until an encoded video fits within the upload limit.
+	qualitySteps []int
+}
+
+var videoCodecs = map[string]videoCodec{
+	outputFormatMP4: {
+		format: "mp4",
+		args: []string{
+			"-c:v", "libx264",
+			"-preset", "veryfast",
+			"-pix_fmt", "yuv420p",
+			"-c:a", "aac",
+			"-b:a", "128k",
+			"-movflags", "+faststart",
+		},
+		qualitySteps: []int{23, 28, 33, 38},
+	},
+	outputFormatWebM: {
+		format: "webm",
+		args: []string{
+			"-c:v", "libvpx-vp9",
+			"-b:v", "0",
+			"-deadline", "good",
+			"-cpu-used", "4",
+			"-row-mt", "1",
+			"-pix_fmt", "yuva420p",
+			"-c:a", "libopus",
+			"-b:a", "96k",
+		},
+		qualitySteps: []int{32, 38, 44, 50},
+	},
+}
+
+// audioSource is a video whose audio track is muxed into an encoded result.
+type audioSource struct {
+	path     string
+	duration float64
+}
+
+// encodeVideo runs ffmpeg to encode the frames listed in a concat script, muxing in audio if a source is given.
+func encodeVideo(
+	ctx context.Context,
+	codec videoCodec,
+	listPath string,
+	audio *audioSource,
+	outputPath string,
+	width uint,
+	height uint,
+	crf int,
+) ([]byte, error) {
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-f", "concat",
+		"-safe", "0",
+		"-i", listPath,
+	}
+	if audio != nil {
+		args = append(
+			args,
+			"-t", fmt.Sprintf("%.3f", audio.duration),
+			"-i", audio.path,
+			"-map", "0:v:0",
+			"-map", "1:a:0?",
+			"-shortest",
+		)
+	}
+	args = append(args, "-vf", fmt.Sprintf("scale=%d:%d", width, height), "-fps_mode", "vfr")
+	args = append(args, codec.args...)
+	args = append(args, "-crf", strconv.Itoa(crf), "-y", outputPath)
+
+	if _, err := runMediaCommand(ctx, "ffmpeg", args...); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(outputPath)
+}
+
+// encodeFramesToVideo encodes result frames as a video, keeping the timing of each frame and lowering the quality
+// until the result fits within the upload limit.
+func encodeFramesToVideo(
+	ctx context.Context,
+	frames []*imagick.MagickWand,
+	codec videoCodec,
+	audio *audioSource,
+	uploadLimit int64,
+) (*cachedResult, error) {
+	dir, err := os.MkdirTemp("", "borik-encode-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Error().Err(err).Msg("Failed to remove temporary encoding directory")
+		}
+	}()
+
+	listPath, err := writeConcatList(frames, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	width := evenDimension(frames[0].GetImageWidth())
+	height := evenDimension(frames[0].GetImageHeight())
+	outputPath := filepath.Join(dir, "output."+codec.format)
+
+	var encoded []byte
+	var reductions string
+	for step, crf := range codec.qualitySteps {
+		if step > 0 {
+			reductions = "reduced its quality"
+		}
+		encoded, err = encodeVideo(ctx, codec, listPath, audio, outputPath, width, height, crf)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding %s: %w", codec.format, err)
+		}
+		if int64(len(encoded)) <= uploadLimit {
+			break
+		}
+		log.Debug().Int("size", len(encoded)).Int("crf", crf).Msg("Encoded video exceeds upload limit, reducing quality")
+	}
+	if int64(len(encoded)) > uploadLimit {
+		return nil, errResultTooLarge
+	}
+
+	return &cachedResult{
+		Format:  codec.format,
+		Content: uploadLimitMessage(uploadLimit, reductions),
+		Blob:    encoded,
+	}, nil
+}
+
+// evenDimension rounds a dimension down to the nearest even number, as required by yuv420p.
+func evenDimension(dimension uint) uint {
+	return max(2, dimension-dimension%2)
+}
+
+// processVideo extracts the frames of a video, runs an operation on each of them, and re-encodes the result as a video
+// with the original audio.
+func processVideo(
+	ctx context.Context,
+	srcBytes []byte,
+	filename string,
+	operation frameOperation,
+	workers int,
+	uploadLimit int64,
+	outputFormat string,
+) (*cachedResult, error) {
+	dir, err := os.MkdirTemp("", "borik-video-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Error().Err(err).Msg("Failed to remove temporary video directory")
+		}
+	}()
+
+	inputPath := filepath.Join(dir, "input"+path.Ext(filename))
+	if err := os.WriteFile(inputPath, srcBytes, 0o600); err != nil {
+		return nil, fmt.Errorf("error writing temporary video file: %w", err)
+	}
+
+	unreadable := "I couldn't read that video. It may be corrupt, or in a format I don't support."
+
+	info, err := probeVideo(ctx, inputPath)
+	if err != nil {
+		return nil, withUserMessage(fmt.Errorf("error probing video: %w", err), unreadable)
+	}
+
+	duration, frameRate, truncated := videoSampling(info)
+	log.Debug().
+		Float64("duration", duration).
+		Float64("frame_rate", frameRate).
+		Bool("truncated", truncated).
+		Msg("Extracting video frames")
+
+	inputFrames, err := extractVideoFrames(ctx, inputPath, dir, duration, frameRate)
+	if err != nil {
+		return nil, withUserMessage(fmt.Errorf("error extracting video frames: %w", err), unreadable)
+	}
+
+	timing := frameTiming{
+		delay:          uint(math.Round(videoTicksPerSecond / frameRate)),
+		ticksPerSecond: videoTicksPerSecond,
+		dispose:        imagick.DISPOSE_NONE,
+	}
+
+	outputs, err := processFrames(
+		ctx,
+		inputFrames,
+		workers,
+		func(opCtx context.Context, _ int, frame *imagick.MagickWand) ([]*imagick.MagickWand, error) {
+			output, err := operation(opCtx, frame)
+			if err != nil {
+				return nil, err
+			}
+			if err := applyFrameTiming(output, timing, 0); err != nil {
+				return nil, fmt.Errorf("error applying frame timing: %w", err)
+			}
+			return output, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var resultFrames []*imagick.MagickWand
+	for _, output := range outputs {
+		resultFrames = append(resultFrames, output...)
+	}
+	if len(resultFrames) == 0 {
+		return nil, errors.New("operation produced no frames")
+	}
+
+	result, err := encodeAnimatedResult(ctx, resultFrames, videoOutputFormat(outputFormat), uploadLimit, &audioSource{
+		path:     inputPath,
+		duration: duration,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []string
+	if truncated {
+		notes = append(notes, fmt.Sprintf(
+			"Only the first %s of the video was processed.",
+			time.Duration(duration*float64(time.Second)).Round(time.Second),
+		))
+	}
+	if result.Content != "" {
+		notes = append(notes, result.Content)
+	}
+	result.Content = strings.Join(notes, "\n")
+
+	return result, nil
+}
@@ -0,0 +1,199 @@
+package bot
+
+import (
+	"math"
+	"mime"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+
+	configPkg "github.com/fogo-sh/borik/pkg/config"
+)
+
+// ftypBox builds the start of an ISO base media file with the given major brand.
+func ftypBox(brand string) []byte {
+	return append([]byte("\x00\x00\x00\x14ftyp"+brand+"\x00\x00\x00\x00"), brand...)
+}
+
+func TestIsVideo(t *testing.T) {
+	tests := []struct {
+		name     string
+		blob     []byte
+		filename string
+		want     bool
+	}{
+		{name: "webm", blob: []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01"), filename: "clip", want: true},
+		{name: "mp4", blob: ftypBox("isom"), filename: "clip", want: true},
+		{name: "quicktime", blob: ftypBox("qt  "), filename: "clip", want: true},
+		{name: "avif", blob: ftypBox("avif"), filename: "clip.mp4", want: false},
+		{name: "heic", blob: ftypBox("heic"), filename: "clip.mp4", want: false},
+		{name: "png", blob: pngSignature, filename: "image.png", want: false},
+		{name: "gif", blob: []byte("GIF89a"), filename: "image.gif", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isVideo(test.blob, test.filename); got != test.want {
+				t.Errorf("isVideo = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsVideoFallsBackToExtension(t *testing.T) {
+	if mime.TypeByExtension(".mp4") == "" {
+		t.Skip("no MIME type is registered for .mp4 on this system")
+	}
+
+	if !isVideo([]byte("unrecognised"), "clip.MP4") {
+		t.Error("an unrecognised file with a video extension wasn't treated as a video")
+	}
+	if isVideo([]byte("unrecognised"), "notes.txt") {
+		t.Error("an unrecognised file with a text extension was treated as a video")
+	}
+}
+
+func TestVideoSampling(t *testing.T) {
+	tests := []struct {
+		name          string
+		info          videoInfo
+		maxFrames     uint
+		wantDuration  float64
+		wantFrameRate float64
+		wantTruncated bool
+	}{
+		{
+			name:          "within limits",
+			info:          videoInfo{duration: 10, frameRate: 10},
+			maxFrames:     300,
+			wantDuration:  10,
+			wantFrameRate: 10,
+		},
+		{
+			name:          "frame rate capped",
+			info:          videoInfo{duration: 20, frameRate: 30},
+			maxFrames:     300,
+			wantDuration:  20,
+			wantFrameRate: 15,
+		},
+		{
+			name:          "too long",
+			info:          videoInfo{duration: 60, frameRate: 10},
+			maxFrames:     300,
+			wantDuration:  30,
+			wantFrameRate: 10,
+			wantTruncated: true,
+		},
+		{
+			name:          "unknown duration and frame rate",
+			info:          videoInfo{},
+			maxFrames:     0,
+			wantDuration:  30,
+			wantFrameRate: 15,
+		},
+		{
+			name:          "frame rate lowered to fit the frame limit",
+			info:          videoInfo{duration: 25, frameRate: 60},
+			maxFrames:     300,
+			wantDuration:  25,
+			wantFrameRate: 12,
+		},
+	}
+
+	previous := Instance
+	t.Cleanup(func() { Instance = previous })
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			Instance = &Bot{config: &configPkg.Config{
+				VideoMaxDuration: 30 * time.Second,
+				VideoMaxFps:      15,
+				MaxInputFrames:   test.maxFrames,
+			}}
+
+			duration, frameRate, truncated := videoSampling(test.info)
+			if duration != test.wantDuration || frameRate != test.wantFrameRate || truncated != test.wantTruncated {
+				t.Errorf(
+					"videoSampling = (%v, %v, %v), want (%v, %v, %v)",
+					duration, frameRate, truncated,
+					test.wantDuration, test.wantFrameRate, test.wantTruncated,
+				)
+			}
+		})
+	}
+}
+
+func TestConcatFrameDuration(t *testing.T) {
+	tests := []struct {
+		name           string
+		delay          uint
+		ticksPerSecond uint
+		want           float64
+	}{
+		{name: "centiseconds", delay: 4, ticksPerSecond: 100, want: 0.04},
+		{name: "other tick rate", delay: 30, ticksPerSecond: 1000, want: 0.03},
+		{name: "missing tick rate", delay: 25, ticksPerSecond: 0, want: 0.25},
+		{name: "no delay", delay: 0, ticksPerSecond: 100, want: 0.1},
+		{name: "no delay at another tick rate", delay: 0, ticksPerSecond: 1000, want: 0.1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := concatFrameDuration(test.delay, test.ticksPerSecond); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("concatFrameDuration(%d, %d) = %v, want %v", test.delay, test.ticksPerSecond, got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteConcatListDefaultsZeroDelays(t *testing.T) {
+	animation := newTestAnimation(t, [2]uint{4, 4}, [2]uint{4, 4})
+	frames := make([]*imagick.MagickWand, 0, 2)
+	for index, delay := range []uint{0, 25} {
+		animation.SetIteratorIndex(index)
+		frame := animation.GetImage()
+		t.Cleanup(frame.Destroy)
+		if err := frame.SetImageDelay(delay); err != nil {
+			t.Fatalf("error setting frame delay: %v", err)
+		}
+		frames = append(frames, frame)
+	}
+
+	listPath, err := writeConcatList(frames, t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	script, err := os.ReadFile(listPath)
+	if err != nil {
+		t.Fatalf("error reading concat list: %v", err)
+	}
+
+	for _, want := range []string{"duration 0.1000\n", "duration 0.2500\n"} {
+		if !strings.Contains(string(script), want) {
+			t.Errorf("concat list doesn't contain %q:\n%s", want, script)
+		}
+	}
+	if strings.Contains(string(script), "duration 0.0000") {
+		t.Errorf("concat list contains a frame with no duration:\n%s", script)
+	}
+}
+
+func TestVideoOutputFormat(t *testing.T) {
+	tests := map[string]string{
+		outputFormatGIF:  outputFormatMP4,
+		outputFormatWebP: outputFormatMP4,
+		outputFormatAPNG: outputFormatMP4,
+		outputFormatMP4:  outputFormatMP4,
+		outputFormatWebM: outputFormatWebM,
+		outputFormatAuto: outputFormatAuto,
+	}
+
+	for format, want := range tests {
+		if got := videoOutputFormat(format); got != want {
+			t.Errorf("videoOutputFormat(%q) = %q, want %q", format, got, want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
@@ -86,49 +87,49 @@ func mirrorImage(wand *imagick.MagickWand, direction mirrorDirection, flipped bo
 }
 
 type WaawArgs struct {
-	ImageURL string `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL string `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 }
 
 func (args WaawArgs) GetImageURL() string {
 	return args.ImageURL
 }
 
-func Waaw(wand *imagick.MagickWand, args WaawArgs) ([]*imagick.MagickWand, error) {
+func Waaw(_ context.Context, wand *imagick.MagickWand, args WaawArgs) ([]*imagick.MagickWand, error) {
 	return mirrorImage(wand, mirrorDirectionHorizontal, true)
 }
 
 type HaahArgs struct {
-	ImageURL string `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL string `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 }
 
 func (args HaahArgs) GetImageURL() string {
 	return args.ImageURL
 }
 
-func Haah(wand *imagick.MagickWand, args HaahArgs) ([]*imagick.MagickWand, error) {
+func Haah(_ context.Context, wand *imagick.MagickWand, args HaahArgs) ([]*imagick.MagickWand, error) {
 	return mirrorImage(wand, mirrorDirectionHorizontal, false)
 }
 
 type WoowArgs struct {
-	ImageURL string `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL string `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 }
 
 func (args WoowArgs) GetImageURL() string {
 	return args.ImageURL
 }
 
-func Woow(wand *imagick.MagickWand, args WoowArgs) ([]*imagick.MagickWand, error) {
+func Woow(_ context.Context, wand *imagick.MagickWand, args WoowArgs) ([]*imagick.MagickWand, error) {
 	return mirrorImage(wand, mirrorDirectionVertical, false)
 }
 
 type HoohArgs struct {
-	ImageURL string `default:"" description:"URL to the image to process. Leave blank to automatically attempt to find an image."`
+	ImageURL string `default:"" cache:"-" description:"URL to the image to process, #N to pick one from a message, or #all for each. Blank to auto-find."`
 }
 
 func (args HoohArgs) GetImageURL() string {
 	return args.ImageURL
 }
 
-func Hooh(wand *imagick.MagickWand, args HoohArgs) ([]*imagick.MagickWand, error) {
+func Hooh(_ context.Context, wand *imagick.MagickWand, args HoohArgs) ([]*imagick.MagickWand, error) {
 	return mirrorImage(wand, mirrorDirectionVertical, true)
 }
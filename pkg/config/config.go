@@ -3,7 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -21,6 +23,26 @@ func (p *Prefixes) Decode(value string) error {
 	return nil
 }
 
+// Formats that animated results can be encoded to.
+const (
+	OutputFormatGIF  = "gif"
+	OutputFormatMP4  = "mp4"
+	OutputFormatWebM = "webm"
+	OutputFormatWebP = "webp"
+	OutputFormatAPNG = "apng"
+	// OutputFormatAuto encodes a result in every format, keeping whichever is smallest.
+	OutputFormatAuto = "auto"
+)
+
+var outputFormats = []string{
+	OutputFormatGIF,
+	OutputFormatMP4,
+	OutputFormatWebM,
+	OutputFormatWebP,
+	OutputFormatAPNG,
+	OutputFormatAuto,
+}
+
 // Config represents the config that Borik will use to run.
 type Config struct {
 	Prefixes Prefixes `default:"borik!"`
@@ -31,6 +53,53 @@ type Config struct {
 
 	RegisterSlashCommandsGlobally bool `default:"false" split_words:"true"`
 
+	UploadLimit int64 `default:"10485760" split_words:"true"`
+
+	JobWorkers   int `default:"0" split_words:"true"`
+	JobsPerUser  int `default:"2" split_words:"true"`
+	JobsPerGuild int `default:"4" split_words:"true"`
+	JobQueueSize int `default:"50" split_words:"true"`
+	FrameWorkers int `default:"0" split_words:"true"`
+
+	ProgressInterval time.Duration `default:"3s" split_words:"true"`
+
+	ResultActionsMaxAge time.Duration `default:"24h" split_words:"true"`
+	ChannelHistorySize  int           `default:"20" split_words:"true"`
+
+	ContextMenuCommands []string `default:"magik,deepfry,meme,gif" split_words:"true"`
+
+	CacheSize   int64         `default:"268435456" split_words:"true"`
+	CacheDir    string        `default:"" split_words:"true"`
+	CacheMaxAge time.Duration `default:"168h" split_words:"true"`
+
+	OperationTimeout time.Duration            `default:"5m" split_words:"true"`
+	CommandTimeouts  map[string]time.Duration `default:"ailoopedit:15m,aiflipflop:15m,ailoopzoom:15m" split_words:"true"`
+
+	MaxDownloadSize int64  `default:"52428800" split_words:"true"`
+	MaxInputPixels  uint64 `default:"40000000" split_words:"true"`
+	MaxInputFrames  uint   `default:"500" split_words:"true"`
+
+	OutputFormat         string            `default:"gif" split_words:"true"`
+	CommandOutputFormats map[string]string `default:"" split_words:"true"`
+
+	VideoMaxDuration  time.Duration `default:"30s" split_words:"true"`
+	VideoMaxFps       float64       `default:"15" split_words:"true"`
+	VideoMaxDimension uint          `default:"640" split_words:"true"`
+
+	ImagemagickMemoryLimit uint64 `default:"1073741824" split_words:"true"`
+	ImagemagickMapLimit    uint64 `default:"2147483648" split_words:"true"`
+	ImagemagickDiskLimit   uint64 `default:"4294967296" split_words:"true"`
+
+	DownloadConnectTimeout time.Duration `default:"10s" split_words:"true"`
+	DownloadReadTimeout    time.Duration `default:"60s" split_words:"true"`
+	DownloadMaxRedirects   int           `default:"5" split_words:"true"`
+	DownloadAllowedDomains []string      `default:"" split_words:"true"`
+	DownloadDeniedDomains  []string      `default:"" split_words:"true"`
+	DownloadUserAgent      string        `default:"borik (+https://github.com/fogo-sh/borik)" split_words:"true"`
+
+	ResolvePageMedia bool  `default:"true" split_words:"true"`
+	MaxPageSize      int64 `default:"2097152" split_words:"true"`
+
 	OpenaiBaseUrl        string `default:"https://llm.ops.bootleg.technology/v1" split_words:"true"`
 	OpenaiApiKey         string `default:"" split_words:"true"`
 	OpenaiImageGenModel  string `default:"flux-2-klein-4b" split_words:"true"`
@@ -39,6 +108,13 @@ type Config struct {
 
 var Instance *Config
 
+func validateOutputFormat(format string) error {
+	if !slices.Contains(outputFormats, strings.ToLower(format)) {
+		return fmt.Errorf("invalid output format %q, must be one of %s", format, strings.Join(outputFormats, ", "))
+	}
+	return nil
+}
+
 func Load() error {
 	err := godotenv.Load()
 	if err != nil && !os.IsNotExist(err) {
@@ -57,6 +133,15 @@ func Load() error {
 	}
 	zerolog.SetGlobalLevel(logLevel)
 
+	if err := validateOutputFormat(newConfig.OutputFormat); err != nil {
+		return err
+	}
+	for command, format := range newConfig.CommandOutputFormats {
+		if err := validateOutputFormat(format); err != nil {
+			return fmt.Errorf("error in output format for %s: %w", command, err)
+		}
+	}
+
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
 	Instance = &newConfig